@@ -3,29 +3,53 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
-	"strconv"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
-	"github.com/olekukonko/tablewriter"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	kubeversion "k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 
+	"github.com/xenitab/kube-checker/pkg/api"
+	"github.com/xenitab/kube-checker/pkg/baseline"
 	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/diff"
+	"github.com/xenitab/kube-checker/pkg/events"
 	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/metrics"
+	"github.com/xenitab/kube-checker/pkg/policyreport"
+	"github.com/xenitab/kube-checker/pkg/report"
+	"github.com/xenitab/kube-checker/pkg/snapshot"
+	"github.com/xenitab/kube-checker/pkg/watch"
+	"github.com/xenitab/kube-checker/pkg/webhook"
 )
 
 //go:embed deprecated-versions.yaml
 var fs embed.FS
 
+// errFailingViolations is returned when violations meet the configured failure thresholds.
+var errFailingViolations = errors.New("violations found that meet the failure threshold")
+
+// version is set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
 func main() {
 	// Load config
 	cfg, err := loadConfig(os.Args[1:])
@@ -46,68 +70,531 @@ func main() {
 	ctx := logr.NewContext(context.Background(), logger)
 
 	// Run application
-	if err := run(ctx, cfg); err != nil {
+	runFn := run
+	if cfg.Serve != nil {
+		runFn = serve
+	}
+	if cfg.Server != nil {
+		runFn = server
+	}
+	if cfg.Diff != nil {
+		runFn = diffScans
+	}
+	if err := runFn(ctx, cfg); err != nil {
+		if errors.Is(err, errFailingViolations) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
 		logger.Error(err, "error running application")
 		os.Exit(1)
 	}
 }
 
 func run(ctx context.Context, cfg config) error {
-	// Get cluster clients
-	client, dynamicClient, err := getKubernetesClients(cfg.KubeConfigPath)
+	contexts, err := kubeContexts(cfg)
 	if err != nil {
 		return err
 	}
+	if len(contexts) > 1 {
+		return runClusters(ctx, cfg, contexts)
+	}
+	kubeContext := cfg.kubeContext()
+	if len(contexts) == 1 {
+		kubeContext = contexts[0]
+	}
 
-	// Check the cluster resources
-	g := graph.NewGraph()
-	err = g.Populate(ctx, client, dynamicClient, cfg.Namespace)
+	g, checker, ruleResults, err := scan(ctx, cfg, kubeContext)
 	if err != nil {
 		return err
 	}
-	checker, err := check.NewChecker(fs)
+	err = publish(ctx, cfg, kubeContext, g, ruleResults)
 	if err != nil {
 		return err
 	}
+	if cfg.SaveSnapshotPath != "" {
+		err := snapshot.New(g, cfg.Namespace, cfg.RedactSecrets).Write(cfg.SaveSnapshotPath)
+		if err != nil {
+			return err
+		}
+	}
 
-	ruleResults, err := checker.Evaluate(g)
+	b, err := g.Encode(graph.Format(cfg.GraphFormat), graphViolations(ruleResults))
 	if err != nil {
 		return err
 	}
+	os.WriteFile(cfg.GraphFile, b, 0644)
 
-	b, err := g.EncodeDot()
+	// Print result
+	r, err := newReport(cfg, kubeContext, g, checker, ruleResults)
 	if err != nil {
 		return err
 	}
-	os.WriteFile(cfg.GraphFile, b, 0644)
+	if cfg.WriteBaselinePath != "" {
+		err := baseline.New(r).Write(cfg.WriteBaselinePath)
+		if err != nil {
+			return fmt.Errorf("could not write baseline: %w", err)
+		}
+	}
+	if cfg.BaselinePath != "" {
+		b, err := baseline.Load(cfg.BaselinePath)
+		if err != nil {
+			return err
+		}
+		b.Apply(&r)
+	}
+	err = report.Write(os.Stdout, report.Format(cfg.Output), r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, r.Summary())
+	if r.Suppressed > 0 {
+		fmt.Fprintf(os.Stderr, "suppressed %d violations\n", r.Suppressed)
+	}
+	if r.Baseline != nil {
+		fmt.Fprintln(os.Stderr, r.Baseline)
+	}
+	if failing := r.Failing(cfg.FailOnSeverity, cfg.FailOnRules); len(failing) > 0 {
+		return fmt.Errorf("%w: %s", errFailingViolations, strings.Join(failing, ", "))
+	}
+	return nil
+}
 
-	// Print result
-	checkTable := tablewriter.NewWriter(os.Stdout)
-	checkTable.SetHeader([]string{"ID", "Severity", "Description"})
-	violationTable := tablewriter.NewWriter(os.Stdout)
-	violationTable.SetHeader([]string{"Api Version", "Kind", "Namespace", "Name", "Message"})
-	for _, r := range ruleResults {
-		if len(r.Violations) == 0 {
-			continue
+// runClusters scans the clusters of the contexts concurrently, each with its own graph, and writes a combined report.
+func runClusters(ctx context.Context, cfg config, contexts []string) error {
+	// Errors are recorded per cluster so that one unreachable cluster does not prevent reporting the others
+	clusterReports := make([]report.ClusterReport, len(contexts))
+	var wg sync.WaitGroup
+	for i, kubeContext := range contexts {
+		i, kubeContext := i, kubeContext
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clusterReports[i] = scanCluster(ctx, cfg, kubeContext)
+		}()
+	}
+	wg.Wait()
+
+	m := report.NewMultiCluster(clusterReports)
+	err := report.WriteMultiCluster(os.Stdout, report.Format(cfg.Output), m)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, m.Summary())
+	if errs := m.Errors(); len(errs) > 0 {
+		return fmt.Errorf("could not scan all contexts: %s", strings.Join(errs, ", "))
+	}
+	if failing := m.Failing(cfg.FailOnSeverity, cfg.FailOnRules); len(failing) > 0 {
+		return fmt.Errorf("%w: %s", errFailingViolations, strings.Join(failing, ", "))
+	}
+	return nil
+}
+
+// scanCluster scans the cluster of the context and publishes the results, the error is set in the report if either fails.
+func scanCluster(ctx context.Context, cfg config, kubeContext string) report.ClusterReport {
+	clusterReport := report.ClusterReport{Context: kubeContext}
+	g, checker, ruleResults, err := scan(ctx, cfg, kubeContext)
+	if err != nil {
+		clusterReport.Error = err.Error()
+		return clusterReport
+	}
+	r, err := newReport(cfg, kubeContext, g, checker, ruleResults)
+	if err != nil {
+		clusterReport.Error = err.Error()
+		return clusterReport
+	}
+	clusterReport.Report = &r
+	err = publish(ctx, cfg, kubeContext, g, ruleResults)
+	if err != nil {
+		clusterReport.Error = fmt.Sprintf("could not publish results: %v", err)
+	}
+	return clusterReport
+}
+
+// graphViolations returns the rules violated by each object keyed by the reference ID, to attach to the exported graph.
+// Violations are reported once per root owner, so the evaluations are used to find every violating object.
+func graphViolations(ruleResults map[string]*check.RuleResult) map[string][]graph.Violation {
+	violations := map[string][]graph.Violation{}
+	seen := map[string]bool{}
+	for _, ruleResult := range ruleResults {
+		for _, evaluation := range ruleResult.Evaluations {
+			if !evaluation.Violated || evaluation.Suppressed {
+				continue
+			}
+			id := evaluation.Object.ID()
+			if seen[id+"\x00"+ruleResult.Rule.ID] {
+				continue
+			}
+			seen[id+"\x00"+ruleResult.Rule.ID] = true
+			violations[id] = append(violations[id], graph.Violation{RuleID: ruleResult.Rule.ID, Severity: ruleResult.Rule.Severity})
 		}
+	}
+	return violations
+}
 
-		fmt.Printf("\n\n\n\n")
+// publish writes policy reports and records events in the cluster if enabled.
+func publish(ctx context.Context, cfg config, kubeContext string, g *graph.Graph, ruleResults map[string]*check.RuleResult) error {
+	if !cfg.PolicyReports && !cfg.Events {
+		return nil
+	}
+	client, dynamicClient, err := getKubernetesClients(cfg.KubeConfigPath, kubeContext)
+	if err != nil {
+		return err
+	}
+	if cfg.PolicyReports {
+		err := policyreport.NewPublisher(dynamicClient, cfg.Namespace).Publish(ctx, ruleResults)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.Events {
+		err := events.NewRecorder(client, cfg.EventsQPS).Record(ctx, ruleResults, g.ObjectUID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newReport creates the report of a scan.
+func newReport(cfg config, kubeContext string, g *graph.Graph, checker *check.Checker, ruleResults map[string]*check.RuleResult) (report.Report, error) {
+	metadata, err := reportMetadata(cfg, kubeContext, g, checker)
+	if err != nil {
+		return report.Report{}, err
+	}
+	r := report.New(metadata, checker.Rules(), ruleResults)
+	if !cfg.ShowSuppressed {
+		r.HideSuppressed()
+	}
+	return r, nil
+}
 
-		checkTable.ClearRows()
-		checkTable.Append([]string{r.Rule.ID, strconv.FormatUint(uint64(r.Rule.Severity), 10), r.Rule.Description})
-		checkTable.Render()
+// scan builds the graph and evaluates all rules.
+func scan(ctx context.Context, cfg config, kubeContext string) (*graph.Graph, *check.Checker, map[string]*check.RuleResult, error) {
+	g, err := populateGraph(ctx, cfg, kubeContext)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts, err := checkerOptions(cfg, g.ServerVersion())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	checker, err := check.NewChecker(fs, opts...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ruleResults, err := checker.Evaluate(g)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return g, checker, ruleResults, nil
+}
 
-		violationTable.ClearRows()
-		for _, v := range r.Violations {
-			violationTable.Append([]string{v.Reference.ApiVersion, v.Reference.Kind, v.Reference.Namespace, v.Reference.Name, v.Message})
+// diffScans compares a snapshot with another snapshot or the cluster.
+func diffScans(ctx context.Context, cfg config) error {
+	// Both scans are scoped to the namespace of the snapshot, otherwise objects outside of it would be reported as added or removed
+	fromMetadata, err := snapshot.LoadMetadata(cfg.Diff.From)
+	if err != nil {
+		return err
+	}
+	namespace := fromMetadata.Namespace
+	if cfg.Diff.To != "" {
+		toMetadata, err := snapshot.LoadMetadata(cfg.Diff.To)
+		if err != nil {
+			return err
 		}
-		violationTable.Render()
+		if toMetadata.Namespace != namespace {
+			return fmt.Errorf("snapshots scoped to different namespaces %q and %q cannot be compared", namespace, toMetadata.Namespace)
+		}
+	}
+	if cfg.Namespace != "" && cfg.Namespace != namespace {
+		return fmt.Errorf("namespace %s does not match the namespace %q of the snapshot", cfg.Namespace, namespace)
+	}
+
+	fromCfg := cfg
+	fromCfg.FromSnapshotPath = cfg.Diff.From
+	fromCfg.Namespace = namespace
+	from, err := diffScan(ctx, fromCfg)
+	if err != nil {
+		return err
+	}
+	toCfg := cfg
+	toCfg.FromSnapshotPath = cfg.Diff.To
+	toCfg.Namespace = namespace
+	to, err := diffScan(ctx, toCfg)
+	if err != nil {
+		return err
+	}
+
+	d := diff.New(from, to)
+	err = diff.Write(os.Stdout, report.Format(cfg.Output), d)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, d)
+	if failing := d.Failing(cfg.FailOnSeverity, cfg.FailOnRules); len(failing) > 0 {
+		return fmt.Errorf("%w: %s", errFailingViolations, strings.Join(failing, ", "))
 	}
 	return nil
 }
 
-func getKubernetesClients(path string) (kubernetes.Interface, dynamic.Interface, error) {
-	cfg, err := getKubernetesConfig(path)
+// diffScan scans either a snapshot or the cluster to compare.
+func diffScan(ctx context.Context, cfg config) (diff.Scan, error) {
+	g, checker, ruleResults, err := scan(ctx, cfg, cfg.kubeContext())
+	if err != nil {
+		return diff.Scan{}, err
+	}
+	r, err := newReport(cfg, cfg.kubeContext(), g, checker, ruleResults)
+	if err != nil {
+		return diff.Scan{}, err
+	}
+	return diff.Scan{Graph: g, Report: r}, nil
+}
+
+// server serves the results of scans over HTTP until the process is stopped.
+func server(ctx context.Context, cfg config) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := logr.FromContextOrDiscard(ctx).WithName("server")
+
+	srv := api.NewServer(func(ctx context.Context) (*api.Scan, error) {
+		logger.Info("scanning")
+		g, checker, ruleResults, err := scan(ctx, cfg, cfg.kubeContext())
+		if err != nil {
+			return nil, err
+		}
+		r, err := newReport(cfg, cfg.kubeContext(), g, checker, ruleResults)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("scan completed", "summary", r.Summary())
+		return &api.Scan{Graph: g, Report: r}, nil
+	}, cfg.Server.ScanToken, logger)
+	_, err := srv.Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		return serveHTTP(ctx, cfg.Server.Address, srv.Handler(ctx), "", "")
+	})
+	if cfg.Server.ScanInterval > 0 {
+		eg.Go(func() error {
+			ticker := time.NewTicker(cfg.Server.ScanInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					_, err := srv.Scan(ctx)
+					if err != nil {
+						logger.Error(err, "scan failed")
+					}
+				}
+			}
+		})
+	}
+	return eg.Wait()
+}
+
+// serveHTTP serves the handler until the context is cancelled, with TLS if a certificate is set.
+func serveHTTP(ctx context.Context, address string, handler http.Handler, certFile, keyFile string) error {
+	srv := &http.Server{Addr: address, Handler: handler}
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		var err error
+		if certFile != "" {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("could not serve on %s: %w", address, err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	})
+	return eg.Wait()
+}
+
+// serve watches the cluster and keeps the results up to date until the process is stopped.
+func serve(ctx context.Context, cfg config) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, dynamicClient, err := getKubernetesClients(cfg.KubeConfigPath, cfg.kubeContext())
+	if err != nil {
+		return err
+	}
+	serverVersion, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("could not get server version: %w", err)
+	}
+	opts, err := checkerOptions(cfg, serverVersion.GitVersion)
+	if err != nil {
+		return err
+	}
+	checker, err := check.NewChecker(fs, opts...)
+	if err != nil {
+		return err
+	}
+	watchOpts := []watch.Option{
+		watch.WithNamespace(cfg.Namespace),
+		watch.WithResyncInterval(cfg.Serve.ResyncInterval),
+	}
+	if cfg.PolicyReports {
+		watchOpts = append(watchOpts, watch.WithResultsHandler(policyreport.NewPublisher(dynamicClient, cfg.Namespace).Publish))
+	}
+	var watcher *watch.Watcher
+	var recorder *events.Recorder
+	if cfg.Events {
+		// Events are recorded asynchronously, as waiting for the rate limit would delay the watcher
+		recorder = events.NewRecorder(client, cfg.EventsQPS)
+		watchOpts = append(watchOpts, watch.WithResultsHandler(func(_ context.Context, results map[string]*check.RuleResult) error {
+			recorder.Queue(results, watcher.ObjectUID)
+			return nil
+		}))
+	}
+	var m *metrics.Metrics
+	if cfg.Serve.MetricsAddress != "" {
+		m = metrics.New()
+		watchOpts = append(watchOpts, watch.WithObserver(m))
+	}
+	watcher = watch.NewWatcher(client, dynamicClient, checker, watchOpts...)
+
+	eg, ctx := errgroup.WithContext(ctx)
+	if m != nil {
+		err = m.RegisterSource(watcher)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		eg.Go(func() error {
+			return serveHTTP(ctx, cfg.Serve.MetricsAddress, mux, "", "")
+		})
+	}
+	if cfg.Serve.WebhookAddress != "" {
+		logger := logr.FromContextOrDiscard(ctx).WithName("webhook")
+		mux := http.NewServeMux()
+		mux.Handle("/validate", webhook.NewWebhook(watcher, cfg.Serve.WebhookDenySeverity, logger))
+		eg.Go(func() error {
+			return serveHTTP(ctx, cfg.Serve.WebhookAddress, mux, cfg.Serve.WebhookCertFile, cfg.Serve.WebhookKeyFile)
+		})
+	}
+	if recorder != nil {
+		eg.Go(func() error {
+			return recorder.Run(ctx)
+		})
+	}
+	eg.Go(func() error {
+		return watcher.Run(ctx)
+	})
+	return eg.Wait()
+}
+
+// checkerOptions returns the checker options for the configuration.
+func checkerOptions(cfg config, serverVersion string) ([]check.Option, error) {
+	opts := []check.Option{
+		check.WithDeprecationFiles(cfg.DeprecationFiles...),
+		check.WithDeprecationComponents(cfg.DeprecationComponents...),
+	}
+	if cfg.RequireIgnoreReason {
+		opts = append(opts, check.WithRequireIgnoreReason())
+	}
+	if len(cfg.customRules) > 0 {
+		opts = append(opts, check.WithRules(cfg.customRules))
+	}
+	if cfg.ruleConfig != nil {
+		opts = append(opts, check.WithConfig(cfg.ruleConfig))
+	}
+	// Fall back to classifying deprecations against the current cluster version
+	targetVersion := cfg.TargetVersion
+	if targetVersion == "" {
+		targetVersion = serverVersion
+	}
+	if targetVersion != "" {
+		v, err := kubeversion.ParseGeneric(targetVersion)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse target version: %w", err)
+		}
+		opts = append(opts, check.WithTargetVersion(v))
+	}
+	return opts, nil
+}
+
+// reportMetadata returns the metadata describing the scan, the cluster is read from the context or the current context if empty.
+func reportMetadata(cfg config, kubeContext string, g *graph.Graph, checker *check.Checker) (report.Metadata, error) {
+	metadata := report.Metadata{
+		Manifests:           cfg.ManifestsPath,
+		Snapshot:            cfg.FromSnapshotPath,
+		Namespace:           cfg.Namespace,
+		Timestamp:           time.Now().UTC(),
+		Version:             version,
+		DeprecationsVersion: checker.DeprecationsVersion(),
+		ServerVersion:       g.ServerVersion(),
+		TargetVersion:       checker.TargetVersion(),
+	}
+	if cfg.ManifestsPath != "" || cfg.FromSnapshotPath != "" || cfg.KubeConfigPath == "" {
+		return metadata, nil
+	}
+	kubeCfg, err := clientcmd.LoadFromFile(cfg.KubeConfigPath)
+	if err != nil {
+		return report.Metadata{}, err
+	}
+	if kubeContext == "" {
+		kubeContext = kubeCfg.CurrentContext
+	}
+	if kubeCfgContext, ok := kubeCfg.Contexts[kubeContext]; ok {
+		metadata.Cluster = kubeCfgContext.Cluster
+	}
+	return metadata, nil
+}
+
+// populateGraph builds the graph either from a directory of manifests, a snapshot or from the cluster.
+func populateGraph(ctx context.Context, cfg config, kubeContext string) (*graph.Graph, error) {
+	g := graph.NewGraph()
+	if cfg.ManifestsPath != "" {
+		err := g.PopulateFromManifests(ctx, cfg.ManifestsPath)
+		if err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+	if cfg.FromSnapshotPath != "" {
+		s, err := snapshot.Load(cfg.FromSnapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		err = s.Populate(ctx, g)
+		if err != nil {
+			return nil, err
+		}
+		return g, nil
+	}
+
+	// Get cluster clients
+	client, dynamicClient, err := getKubernetesClients(cfg.KubeConfigPath, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the cluster resources
+	err = g.Populate(ctx, client, dynamicClient, cfg.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func getKubernetesClients(path, kubeContext string) (kubernetes.Interface, dynamic.Interface, error) {
+	cfg, err := getKubernetesConfig(path, kubeContext)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -122,9 +609,13 @@ func getKubernetesClients(path string) (kubernetes.Interface, dynamic.Interface,
 	return client, dynamicClient, nil
 }
 
-func getKubernetesConfig(path string) (*rest.Config, error) {
+// getKubernetesConfig returns the config of the context in the kubeconfig, or the current context if empty.
+// The in cluster config is used if no kubeconfig path is set.
+func getKubernetesConfig(path, kubeContext string) (*rest.Config, error) {
 	if path != "" {
-		cfg, err := clientcmd.BuildConfigFromFlags("", path)
+		loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+		cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
 		if err != nil {
 			return nil, err
 		}
@@ -138,10 +629,89 @@ func getKubernetesConfig(path string) (*rest.Config, error) {
 	return cfg, nil
 }
 
+// kubeContexts returns the contexts of the clusters to scan, empty to scan the current context.
+func kubeContexts(cfg config) ([]string, error) {
+	if !cfg.AllContexts {
+		return cfg.Contexts, nil
+	}
+	kubeCfg, err := clientcmd.LoadFromFile(cfg.KubeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	contexts := []string{}
+	for name := range kubeCfg.Contexts {
+		contexts = append(contexts, name)
+	}
+	if len(contexts) == 0 {
+		return nil, fmt.Errorf("kubeconfig %s does not contain any contexts", cfg.KubeConfigPath)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
 type config struct {
-	Namespace      string `arg:"--namespace,env:NAMESPACE" help:"the namespace to scope to"`
-	KubeConfigPath string `arg:"--kubeconfig,env:KUBE_CONFIG" help:"path to the kubeconfig file"`
-	GraphFile      string `arg:"--graph-file,env:GRAPH_FILE" help:"path to the stored graph file"`
+	Namespace             string   `arg:"--namespace,env:NAMESPACE" help:"the namespace to scope to"`
+	KubeConfigPath        string   `arg:"--kubeconfig,env:KUBE_CONFIG" help:"path to the kubeconfig file"`
+	Contexts              []string `arg:"--context,separate,env:CONTEXT" help:"kubeconfig context of a cluster to scan instead of the current context, can be repeated to scan several clusters"`
+	AllContexts           bool     `arg:"--all-contexts,env:ALL_CONTEXTS" help:"scan the clusters of all contexts in the kubeconfig"`
+	GraphFile             string   `arg:"--graph-file,env:GRAPH_FILE" help:"path to the stored graph file"`
+	GraphFormat           string   `arg:"--graph-format,env:GRAPH_FORMAT" default:"dot" help:"format of the graph file, one of dot, json or graphml"`
+	ManifestsPath         string   `arg:"--manifests,env:MANIFESTS" help:"path to a directory of manifests to check instead of a cluster"`
+	Output                string   `arg:"--output,env:OUTPUT" default:"table" help:"output format, one of table, json, sarif, junit or upgrade"`
+	FailOnSeverity        uint     `arg:"--fail-on-severity,env:FAIL_ON_SEVERITY" help:"exit with code 2 if any violation has a severity equal to or above this value"`
+	FailOnRules           []string `arg:"--fail-on-rule,separate,env:FAIL_ON_RULE" help:"exit with code 2 if any violation is of a rule matching this ID pattern, can be repeated"`
+	TargetVersion         string   `arg:"--target-version,env:TARGET_VERSION" help:"kubernetes version to classify deprecations against, defaults to the cluster version"`
+	DeprecationFiles      []string `arg:"--deprecations-file,separate,env:DEPRECATIONS_FILE" help:"path to a file with deprecations merged on top of the embedded ones, can be repeated"`
+	DeprecationComponents []string `arg:"--deprecations-component,separate,env:DEPRECATIONS_COMPONENT" help:"only check deprecations of this component, can be repeated"`
+	ConfigPath            string   `arg:"--config,env:CONFIG" help:"path to a rule configuration file"`
+	ShowSuppressed        bool     `arg:"--show-suppressed,env:SHOW_SUPPRESSED" help:"list violations suppressed by ignore annotations"`
+	RequireIgnoreReason   bool     `arg:"--require-ignore-reason,env:REQUIRE_IGNORE_REASON" help:"only suppress violations if the ignore reason annotation is set"`
+	BaselinePath          string   `arg:"--baseline,env:BASELINE" help:"path to a baseline file with violations which should not be reported, violations are matched by rule, message and root owner so they cover every object of the owner"`
+	WriteBaselinePath     string   `arg:"--write-baseline,env:WRITE_BASELINE" help:"path to write a baseline file with the current violations to"`
+	CELRulesPaths         []string `arg:"--cel-rules,separate,env:CEL_RULES" help:"path to a file with custom rules using CEL expressions, can be repeated"`
+	RegoRulesPath         string   `arg:"--rego-rules,env:REGO_RULES" help:"path to a directory with rego policies to evaluate as rules"`
+	SaveSnapshotPath      string   `arg:"--save-snapshot,env:SAVE_SNAPSHOT" help:"path to save a snapshot of all objects in the graph to"`
+	RedactSecrets         bool     `arg:"--redact-secrets,env:REDACT_SECRETS" help:"remove the data of secrets from the saved snapshot"`
+	FromSnapshotPath      string   `arg:"--from-snapshot,env:FROM_SNAPSHOT" help:"path to a snapshot to check instead of a cluster"`
+	PolicyReports         bool     `arg:"--policy-reports,env:POLICY_REPORTS" help:"write the violations as PolicyReport and ClusterPolicyReport resources to the cluster"`
+	Events                bool     `arg:"--events,env:EVENTS" help:"record warning events on the objects of violations"`
+	EventsQPS             float64  `arg:"--events-qps,env:EVENTS_QPS" default:"5" help:"maximum number of event requests per second"`
+
+	Serve  *serveConfig  `arg:"subcommand:serve" help:"watch the cluster and keep the results up to date"`
+	Server *serverConfig `arg:"subcommand:server" help:"serve the results of scans with a JSON API"`
+	Diff   *diffConfig   `arg:"subcommand:diff" help:"compare the objects, edges and violations of two snapshots, or of a snapshot and the cluster"`
+
+	ruleConfig  *check.Config           `arg:"-"`
+	customRules map[string][]check.Rule `arg:"-"`
+}
+
+// kubeContext returns the context if a single one is set, empty for the current context.
+func (cfg config) kubeContext() string {
+	if len(cfg.Contexts) == 1 {
+		return cfg.Contexts[0]
+	}
+	return ""
+}
+
+type serveConfig struct {
+	ResyncInterval time.Duration `arg:"--resync-interval,env:RESYNC_INTERVAL" default:"5m" help:"interval at which all objects are evaluated again"`
+	MetricsAddress string        `arg:"--metrics-address,env:METRICS_ADDRESS" default:":8080" help:"address to serve prometheus metrics on, disabled if empty"`
+	// The webhook is served with TLS as required by the api server
+	WebhookAddress      string `arg:"--webhook-address,env:WEBHOOK_ADDRESS" help:"address to serve the validating admission webhook on, disabled if empty"`
+	WebhookCertFile     string `arg:"--webhook-cert-file,env:WEBHOOK_CERT_FILE" help:"path to the TLS certificate of the webhook"`
+	WebhookKeyFile      string `arg:"--webhook-key-file,env:WEBHOOK_KEY_FILE" help:"path to the TLS key of the webhook"`
+	WebhookDenySeverity uint   `arg:"--webhook-deny-severity,env:WEBHOOK_DENY_SEVERITY" default:"10" help:"deny objects with violations of this severity or above, lower severities are returned as warnings, 0 never denies"`
+}
+
+type serverConfig struct {
+	Address      string        `arg:"--address,env:ADDRESS" default:":8080" help:"address to serve the API on"`
+	ScanInterval time.Duration `arg:"--scan-interval,env:SCAN_INTERVAL" help:"interval at which scans are run, only scans requested through the API are run if not set"`
+	ScanToken    string        `arg:"--scan-token,env:SCAN_TOKEN" help:"bearer token required to request scans through the API, requesting scans is disabled if not set"`
+}
+
+type diffConfig struct {
+	From string `arg:"positional,required" help:"path to the snapshot to compare from"`
+	To   string `arg:"positional" help:"path to the snapshot to compare to, the cluster is used if not set"`
 }
 
 func loadConfig(args []string) (config, error) {
@@ -161,12 +731,125 @@ func loadConfig(args []string) (config, error) {
 		return config{}, err
 	}
 
+	if _, err := report.ParseFormat(cfg.Output); err != nil {
+		return config{}, err
+	}
+	if _, err := graph.ParseFormat(cfg.GraphFormat); err != nil {
+		return config{}, err
+	}
+	multiCluster := len(cfg.Contexts) > 1 || cfg.AllContexts
+	if (len(cfg.Contexts) > 0 || cfg.AllContexts) && cfg.ManifestsPath != "" {
+		return config{}, fmt.Errorf("contexts cannot be set when checking manifests")
+	}
+	if (len(cfg.Contexts) > 0 || cfg.AllContexts) && cfg.KubeConfigPath == "" {
+		return config{}, fmt.Errorf("kubeconfig has to be set to select contexts")
+	}
+	if len(cfg.Contexts) > 0 && cfg.AllContexts {
+		return config{}, fmt.Errorf("contexts cannot be set together with all contexts")
+	}
+	if multiCluster && (cfg.Serve != nil || cfg.Server != nil) {
+		return config{}, fmt.Errorf("only a single context can be used in serve and server mode")
+	}
+	if multiCluster && !report.SupportsMultiCluster(report.Format(cfg.Output)) {
+		return config{}, fmt.Errorf("output format %s is not supported for multiple clusters", cfg.Output)
+	}
+	if multiCluster && (cfg.BaselinePath != "" || cfg.WriteBaselinePath != "") {
+		return config{}, fmt.Errorf("baselines cannot be used with multiple clusters")
+	}
+	if cfg.FromSnapshotPath != "" && (cfg.ManifestsPath != "" || cfg.KubeConfigPath != "") {
+		return config{}, fmt.Errorf("a snapshot cannot be checked together with manifests or a cluster")
+	}
+	if cfg.FromSnapshotPath != "" && cfg.Namespace != "" {
+		return config{}, fmt.Errorf("namespace cannot be set when checking a snapshot, the snapshot is checked as it was saved")
+	}
+	if cfg.FromSnapshotPath != "" && (cfg.Serve != nil || cfg.PolicyReports || cfg.Events) {
+		return config{}, fmt.Errorf("a snapshot cannot be checked in serve mode or with policy reports or events")
+	}
+	if cfg.SaveSnapshotPath != "" && (cfg.ManifestsPath != "" || cfg.FromSnapshotPath != "") {
+		return config{}, fmt.Errorf("snapshots can only be saved when checking a cluster")
+	}
+	if cfg.SaveSnapshotPath != "" && (multiCluster || cfg.Serve != nil || cfg.Server != nil) {
+		return config{}, fmt.Errorf("snapshots can only be saved when checking a single cluster once")
+	}
+	if cfg.RedactSecrets && cfg.SaveSnapshotPath == "" {
+		return config{}, fmt.Errorf("redact secrets requires a snapshot to be saved")
+	}
+	if cfg.Diff != nil && (cfg.ManifestsPath != "" || cfg.FromSnapshotPath != "" || cfg.SaveSnapshotPath != "" || multiCluster) {
+		return config{}, fmt.Errorf("diff compares snapshots or a snapshot and a single cluster, manifests, snapshot flags and multiple contexts cannot be used")
+	}
+	if cfg.Diff != nil && (cfg.PolicyReports || cfg.Events || cfg.BaselinePath != "" || cfg.WriteBaselinePath != "") {
+		return config{}, fmt.Errorf("policy reports, events and baselines cannot be used with diff")
+	}
+	if cfg.Diff != nil && cfg.Diff.To != "" && cfg.Namespace != "" {
+		return config{}, fmt.Errorf("namespace cannot be set when comparing snapshots")
+	}
+	if cfg.Diff != nil && cfg.Output != string(report.FormatTable) && cfg.Output != string(report.FormatJSON) {
+		return config{}, fmt.Errorf("output format %s is not supported for diffs", cfg.Output)
+	}
+	if cfg.Serve != nil && cfg.ManifestsPath != "" {
+		return config{}, fmt.Errorf("manifests cannot be watched in serve mode")
+	}
+	if cfg.Serve != nil && cfg.Serve.ResyncInterval <= 0 {
+		return config{}, fmt.Errorf("resync interval has to be greater than zero")
+	}
+	if cfg.Serve != nil && cfg.Serve.WebhookAddress != "" && (cfg.Serve.WebhookCertFile == "" || cfg.Serve.WebhookKeyFile == "") {
+		return config{}, fmt.Errorf("webhook cert file and key file have to be set to serve the webhook")
+	}
+	if cfg.Serve != nil && cfg.Serve.WebhookDenySeverity > 10 {
+		return config{}, fmt.Errorf("webhook deny severity cannot be greater than 10")
+	}
+	if cfg.PolicyReports && cfg.ManifestsPath != "" {
+		return config{}, fmt.Errorf("policy reports cannot be written when checking manifests")
+	}
+	if cfg.Events && cfg.ManifestsPath != "" {
+		return config{}, fmt.Errorf("events cannot be recorded when checking manifests")
+	}
+	if cfg.EventsQPS <= 0 {
+		return config{}, fmt.Errorf("events qps has to be greater than zero")
+	}
+	if cfg.TargetVersion != "" {
+		if _, err := kubeversion.ParseGeneric(cfg.TargetVersion); err != nil {
+			return config{}, fmt.Errorf("could not parse target version: %w", err)
+		}
+	}
+	if cfg.FailOnSeverity > 10 {
+		return config{}, fmt.Errorf("fail on severity cannot be greater than 10")
+	}
+	if err := report.ValidateRulePatterns(cfg.FailOnRules); err != nil {
+		return config{}, err
+	}
+	if len(cfg.CELRulesPaths) > 0 {
+		customRules, err := check.LoadCELRules(cfg.CELRulesPaths...)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.customRules = customRules
+	}
+	if cfg.RegoRulesPath != "" {
+		regoRules, err := check.LoadRegoRules(context.Background(), cfg.RegoRulesPath)
+		if err != nil {
+			return config{}, err
+		}
+		customRules, err := check.MergeRules(cfg.customRules, regoRules)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.customRules = customRules
+	}
+	if cfg.ConfigPath != "" {
+		ruleConfig, err := check.LoadConfig(cfg.ConfigPath, cfg.customRules)
+		if err != nil {
+			return config{}, err
+		}
+		cfg.ruleConfig = ruleConfig
+	}
+
 	if cfg.GraphFile == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
 			return config{}, err
 		}
-		cfg.GraphFile = path.Join(homeDir, "graph.dot")
+		cfg.GraphFile = path.Join(homeDir, "graph."+cfg.GraphFormat)
 	}
 
 	return cfg, nil
@@ -0,0 +1,331 @@
+// Package api serves the results of scans and queries of the graph over HTTP.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-logr/logr"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+)
+
+// Scan is the graph and report of a single scan.
+type Scan struct {
+	Graph  *graph.Graph
+	Report report.Report
+}
+
+// ScanFunc builds a new graph and evaluates the rules.
+type ScanFunc func(ctx context.Context) (*Scan, error)
+
+// Server serves the results of the latest scan.
+type Server struct {
+	scanFunc ScanFunc
+	// scanToken is the bearer token required to request scans, requesting scans is disabled if empty.
+	scanToken string
+	logger    logr.Logger
+
+	// scanning holds a value while a scan is running to prevent concurrent scans.
+	scanning chan struct{}
+	mu       sync.RWMutex
+	scan     *Scan
+}
+
+func NewServer(scanFunc ScanFunc, scanToken string, logger logr.Logger) *Server {
+	return &Server{
+		scanFunc:  scanFunc,
+		scanToken: scanToken,
+		logger:    logger,
+		scanning:  make(chan struct{}, 1),
+	}
+}
+
+// Scan runs a new scan and replaces the results served once it has completed, waiting for
+// a running scan to complete first.
+func (s *Server) Scan(ctx context.Context) (*Scan, error) {
+	select {
+	case s.scanning <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return s.runScan(ctx)
+}
+
+// runScan runs a scan, the caller has to hold the scanning slot.
+func (s *Server) runScan(ctx context.Context) (*Scan, error) {
+	defer func() { <-s.scanning }()
+	scan, err := s.scanFunc(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.scan = scan
+	s.mu.Unlock()
+	return scan, nil
+}
+
+// Handler returns the handler serving the API. Scans requested through the API run with the context,
+// so that they are not cancelled when the client disconnects.
+func (s *Server) Handler(ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/results", s.handleResults)
+	mux.HandleFunc("/objects/", s.handleObject)
+	mux.HandleFunc("/scan", func(w http.ResponseWriter, req *http.Request) {
+		s.handleScan(ctx, w, req)
+	})
+	return mux
+}
+
+// ObjectResponse is an object in the graph together with its violations and edges.
+type ObjectResponse struct {
+	Object graph.ObjectReference `json:"object"`
+	// Manifest is the full object.
+	Manifest map[string]interface{} `json:"manifest"`
+	// RootOwner is the top most owner of the object, which is the object itself if it has no owner.
+	RootOwner  graph.ObjectReference `json:"rootOwner"`
+	Violations []ObjectViolation     `json:"violations"`
+	Edges      []Edge                `json:"edges"`
+}
+
+// ObjectViolation is a violation of a rule by the object or by objects it is the root owner of.
+type ObjectViolation struct {
+	Rule report.Rule `json:"rule"`
+	report.Violation
+}
+
+// Edge is an edge to or from an object. The direction is "to" for edges from the
+// object and "from" for edges to the object.
+type Edge struct {
+	Type      graph.EdgeType        `json:"type"`
+	Direction string                `json:"direction"`
+	Object    graph.ObjectReference `json:"object"`
+}
+
+// ScanResponse describes a completed scan.
+type ScanResponse struct {
+	Metadata   report.Metadata `json:"metadata"`
+	Violations int             `json:"violations"`
+}
+
+// ErrorResponse is returned for all failed requests.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// handleResults returns the report, filtered by the rule, severity and namespace query parameters.
+// The rule parameter is a rule ID pattern and can be repeated, severity is the minimum severity.
+func (s *Server) handleResults(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+	query := req.URL.Query()
+	rulePatterns := query["rule"]
+	if err := report.ValidateRulePatterns(rulePatterns); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var severity uint64
+	if v := query.Get("severity"); v != "" {
+		var err error
+		severity, err = strconv.ParseUint(v, 10, 32)
+		if err != nil || severity > 10 {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("severity has to be a number between 0 and 10"))
+			return
+		}
+	}
+	namespace := query.Get("namespace")
+
+	scan, ok := s.currentScan(w)
+	if !ok {
+		return
+	}
+	r := scan.Report
+	r.Results = []report.Result{}
+	for _, result := range scan.Report.Results {
+		if result.Rule.Severity < uint(severity) || !matchesAny(rulePatterns, result.Rule.ID) {
+			continue
+		}
+		result.Violations = filterViolations(result.Violations, namespace)
+		result.Suppressed = filterViolations(result.Suppressed, namespace)
+		if len(result.Violations) == 0 && len(result.Suppressed) == 0 {
+			continue
+		}
+		r.Results = append(r.Results, result)
+	}
+	s.writeJSON(w, http.StatusOK, r)
+}
+
+// handleObject returns the object with the path /objects/{apiVersion}/{kind}/{namespace}/{name},
+// the namespace is left out for cluster wide objects.
+func (s *Server) handleObject(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		s.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+	reference, err := parseObjectPath(strings.TrimPrefix(req.URL.Path, "/objects/"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	scan, ok := s.currentScan(w)
+	if !ok {
+		return
+	}
+	node := scan.Graph.NodeByReferenceID(reference.ID())
+	if node == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("object %s not found", reference.ID()))
+		return
+	}
+
+	resp := ObjectResponse{
+		Object:     node.Reference,
+		Manifest:   node.Unstructured.Object,
+		RootOwner:  scan.Graph.FindRootOwner(node).Reference,
+		Violations: []ObjectViolation{},
+		Edges:      []Edge{},
+	}
+	for _, result := range scan.Report.Results {
+		for _, violations := range [][]report.Violation{result.Violations, result.Suppressed} {
+			for _, violation := range violations {
+				if violation.Object != node.Reference && violation.RootOwner != node.Reference {
+					continue
+				}
+				resp.Violations = append(resp.Violations, ObjectViolation{Rule: result.Rule, Violation: violation})
+			}
+		}
+	}
+	for _, edge := range scan.Graph.Edges(node) {
+		direction := graph.RelationshipDirectionTo
+		other := edge.To().(*graph.Node)
+		if edge.To().ID() == node.ID() {
+			direction = graph.RelationshipDirectionFrom
+			other = edge.From().(*graph.Node)
+		}
+		resp.Edges = append(resp.Edges, Edge{
+			Type:      edge.Type,
+			Direction: string(direction),
+			Object:    other.Reference,
+		})
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleScan runs a new scan and returns once it has completed. The request has to contain the scan token
+// as bearer token, and is rejected if a scan is already running.
+func (s *Server) handleScan(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		s.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+	if s.scanToken == "" {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("requesting scans is disabled"))
+		return
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.scanToken)) != 1 {
+		s.writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid scan token"))
+		return
+	}
+	select {
+	case s.scanning <- struct{}{}:
+	default:
+		s.writeError(w, http.StatusConflict, fmt.Errorf("a scan is already running"))
+		return
+	}
+	scan, err := s.runScan(ctx)
+	if err != nil {
+		s.logger.Error(err, "scan failed")
+		s.writeError(w, http.StatusInternalServerError, fmt.Errorf("scan failed: %w", err))
+		return
+	}
+	violations := 0
+	for _, result := range scan.Report.Results {
+		violations += len(result.Violations)
+	}
+	s.writeJSON(w, http.StatusOK, ScanResponse{Metadata: scan.Report.Metadata, Violations: violations})
+}
+
+func (s *Server) currentScan(w http.ResponseWriter) (*Scan, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.scan == nil {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("no scan has completed yet"))
+		return nil, false
+	}
+	return s.scan, true
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		s.logger.Error(err, "could not write response")
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// parseObjectPath parses a path of the form {apiVersion}/{kind}/{namespace}/{name}. The api version
+// may contain a slash, so the kind is found as the first segment starting with an upper case letter.
+func parseObjectPath(path string) (graph.ObjectReference, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if i == 0 || segment == "" || !unicode.IsUpper([]rune(segment)[0]) {
+			continue
+		}
+		reference := graph.ObjectReference{
+			ApiVersion: strings.Join(segments[:i], "/"),
+			Kind:       segment,
+		}
+		switch rest := segments[i+1:]; len(rest) {
+		case 1:
+			reference.Name = rest[0]
+		case 2:
+			reference.Namespace = rest[0]
+			reference.Name = rest[1]
+		default:
+			return graph.ObjectReference{}, fmt.Errorf("invalid object path %s", path)
+		}
+		return reference, nil
+	}
+	return graph.ObjectReference{}, fmt.Errorf("invalid object path %s", path)
+}
+
+func matchesAny(patterns []string, id string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := check.MatchRuleID(pattern, id); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func filterViolations(violations []report.Violation, namespace string) []report.Violation {
+	if namespace == "" {
+		return violations
+	}
+	filtered := []report.Violation{}
+	for _, violation := range violations {
+		if violation.Object.Namespace == namespace {
+			filtered = append(filtered, violation)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+)
+
+func TestServer(t *testing.T) {
+	scans := 0
+	srv := NewServer(func(ctx context.Context) (*Scan, error) {
+		scans++
+		g := graph.NewGraph()
+		pod := unstructured.Unstructured{}
+		pod.SetAPIVersion("v1")
+		pod.SetKind("Pod")
+		pod.SetNamespace("foo")
+		pod.SetName("debug")
+		require.NoError(t, g.AddUnstructuredNode(pod))
+		reference := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "debug"}
+		rules := []check.Rule{
+			{ID: "WithoutController", Severity: 8},
+			{ID: "MissingReadinessProbe", Severity: 5},
+		}
+		ruleResults := map[string]*check.RuleResult{
+			"WithoutController": {
+				Rule:       rules[0],
+				Violations: []check.Violation{{Reference: reference, Object: reference}},
+			},
+			"MissingReadinessProbe": {
+				Rule:       rules[1],
+				Violations: []check.Violation{{Reference: reference, Object: reference}},
+			},
+		}
+		return &Scan{Graph: g, Report: report.New(report.Metadata{}, rules, ruleResults)}, nil
+	}, "secret", logr.Discard())
+	handler := srv.Handler(context.Background())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, 0, scans)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/scan", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 1, scans)
+
+	results := func(query string) []string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/results"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		r := report.Report{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &r))
+		ids := []string{}
+		for _, result := range r.Results {
+			ids = append(ids, result.Rule.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{"WithoutController", "MissingReadinessProbe"}, results(""))
+	require.Equal(t, []string{"WithoutController"}, results("?severity=6"))
+	require.Equal(t, []string{"MissingReadinessProbe"}, results("?rule=Missing*"))
+	require.Empty(t, results("?namespace=bar"))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/objects/v1/Pod/foo/debug", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := ObjectResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "debug", resp.Object.Name)
+	require.Len(t, resp.Violations, 2)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/objects/v1/Pod/foo/missing", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestParseObjectPath(t *testing.T) {
+	reference, err := parseObjectPath("apps/v1/Deployment/foo/bar")
+	require.NoError(t, err)
+	require.Equal(t, graph.ObjectReference{ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "foo", Name: "bar"}, reference)
+
+	reference, err = parseObjectPath("v1/Node/node-1")
+	require.NoError(t, err)
+	require.Equal(t, graph.ObjectReference{ApiVersion: "v1", Kind: "Node", Name: "node-1"}, reference)
+
+	_, err = parseObjectPath("v1/pod/foo/bar")
+	require.Error(t, err)
+}
+
+func TestServerScan(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := NewServer(func(ctx context.Context) (*Scan, error) {
+		close(started)
+		<-release
+		return &Scan{Graph: graph.NewGraph(), Report: report.New(report.Metadata{}, nil, nil)}, ctx.Err()
+	}, "secret", logr.Discard())
+	handler := srv.Handler(context.Background())
+	scan := func(ctx context.Context) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/scan", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer secret")
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The scan is not cancelled with the request
+	ctx, cancel := context.WithCancel(context.Background())
+	codes := make(chan int)
+	go func() {
+		codes <- scan(ctx)
+	}()
+	<-started
+	cancel()
+	require.Equal(t, http.StatusConflict, scan(context.Background()))
+	close(release)
+	require.Equal(t, http.StatusOK, <-codes)
+
+	disabled := NewServer(func(ctx context.Context) (*Scan, error) {
+		t.Fatal("scan should not run")
+		return nil, nil
+	}, "", logr.Discard())
+	rec := httptest.NewRecorder()
+	disabled.Handler(context.Background()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", nil))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,140 @@
+// Package baseline records the violations of a report so that later reports only contain new violations.
+//
+// Violations are fingerprinted by rule, message and the root owner of the object rather than the object
+// itself, as objects owned by a controller, like pods, are renamed when they are replaced. A baselined
+// violation therefore also covers the same violation of any other object with the same root owner.
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+)
+
+// Version is the version of the baseline file format.
+const Version = "v2"
+
+// Baseline is a set of known violations.
+type Baseline struct {
+	Version string  `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is a known violation. The rule and root owner are only informational, violations are matched by the fingerprint.
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	Rule        string `json:"rule"`
+	RootOwner   string `json:"rootOwner"`
+}
+
+// Fingerprint returns a stable identifier of a violation of a rule with the given message. Violations
+// are identified by their root owner, as the objects it owns, like pods, are renamed when they are replaced.
+func Fingerprint(ruleID string, rootOwner graph.ObjectReference, message string) string {
+	messageHash := sha256.Sum256([]byte(message))
+	h := sha256.New()
+	h.Write([]byte(ruleID))
+	h.Write([]byte{0})
+	h.Write([]byte(rootOwner.ID()))
+	h.Write([]byte{0})
+	h.Write(messageHash[:])
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// New creates a baseline from the violations in the report.
+func New(r report.Report) Baseline {
+	entries := []Entry{}
+	seen := map[string]bool{}
+	for _, result := range r.Results {
+		for _, v := range result.Violations {
+			fingerprint := Fingerprint(result.Rule.ID, v.RootOwner, v.Message)
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			entries = append(entries, Entry{
+				Fingerprint: fingerprint,
+				Rule:        result.Rule.ID,
+				RootOwner:   v.RootOwner.ID(),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Rule != entries[j].Rule {
+			return entries[i].Rule < entries[j].Rule
+		}
+		if entries[i].RootOwner != entries[j].RootOwner {
+			return entries[i].RootOwner < entries[j].RootOwner
+		}
+		return entries[i].Fingerprint < entries[j].Fingerprint
+	})
+	return Baseline{
+		Version: Version,
+		Entries: entries,
+	}
+}
+
+// Load reads a baseline file.
+func Load(path string) (Baseline, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("could not read baseline file: %w", err)
+	}
+	baseline := Baseline{}
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		return Baseline{}, fmt.Errorf("could not unmarshal baseline file %s: %w", path, err)
+	}
+	if baseline.Version != Version {
+		return Baseline{}, fmt.Errorf("unsupported baseline version %q in %s", baseline.Version, path)
+	}
+	return baseline, nil
+}
+
+// Write writes the baseline to a file.
+func (b Baseline) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Apply removes the violations in the baseline from the report and records how the report compares to the baseline.
+func (b Baseline) Apply(r *report.Report) {
+	known := map[string]bool{}
+	for _, entry := range b.Entries {
+		known[entry.Fingerprint] = true
+	}
+	comparison := &report.BaselineComparison{}
+	present := map[string]bool{}
+	results := []report.Result{}
+	for _, result := range r.Results {
+		violations := []report.Violation{}
+		for _, v := range result.Violations {
+			fingerprint := Fingerprint(result.Rule.ID, v.RootOwner, v.Message)
+			if known[fingerprint] {
+				present[fingerprint] = true
+				comparison.Existing++
+				continue
+			}
+			comparison.New++
+			violations = append(violations, v)
+		}
+		result.Violations = violations
+		if len(result.Violations) == 0 && len(result.Suppressed) == 0 {
+			continue
+		}
+		results = append(results, result)
+	}
+	comparison.Fixed = len(known) - len(present)
+	r.Results = results
+	r.Baseline = comparison
+	r.ClearEvaluations(func(ruleID string, rootOwner graph.ObjectReference, message string) bool {
+		return known[Fingerprint(ruleID, rootOwner, message)]
+	})
+}
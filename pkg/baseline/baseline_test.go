@@ -0,0 +1,84 @@
+package baseline
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+)
+
+func TestApply(t *testing.T) {
+	web := graph.ObjectReference{ApiVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "web"}
+	api := graph.ObjectReference{ApiVersion: "networking.k8s.io/v1", Kind: "Ingress", Namespace: "default", Name: "api"}
+	debug := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "default", Name: "debug"}
+	old := report.Report{
+		Results: []report.Result{
+			{
+				Rule:       report.Rule{ID: "NoTLS", Severity: 6},
+				Violations: []report.Violation{{Object: web, RootOwner: web}},
+			},
+			{
+				Rule:       report.Rule{ID: "WithoutController", Severity: 8},
+				Violations: []report.Violation{{Object: debug, RootOwner: debug}},
+			},
+		},
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	require.NoError(t, New(old).Write(path))
+	b, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, b.Entries, 2)
+
+	r := report.Report{
+		Results: []report.Result{
+			{
+				Rule:       report.Rule{ID: "NoTLS", Severity: 6},
+				Violations: []report.Violation{{Object: web, RootOwner: web}, {Object: api, RootOwner: api}},
+			},
+		},
+	}
+	b.Apply(&r)
+	require.Equal(t, &report.BaselineComparison{New: 1, Existing: 1, Fixed: 1}, r.Baseline)
+	require.Len(t, r.Results, 1)
+	require.Equal(t, []report.Violation{{Object: api, RootOwner: api}}, r.Results[0].Violations)
+}
+
+func TestApplyMatchesRootOwner(t *testing.T) {
+	deployment := graph.ObjectReference{ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	pod := func(name string) graph.ObjectReference {
+		return graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "default", Name: name}
+	}
+	rule := check.Rule{ID: "ImagePullPolicyAlways", Severity: 3}
+	newReport := func(podName string) report.Report {
+		violation := check.Violation{Reference: deployment, Object: pod(podName), Message: "container web"}
+		return report.New(report.Metadata{}, nil, map[string]*check.RuleResult{
+			rule.ID: {
+				Rule:        rule,
+				Violations:  []check.Violation{violation},
+				Evaluations: []check.Evaluation{{Object: pod(podName), RootOwner: deployment, Violated: true, Message: "container web"}},
+			},
+		})
+	}
+	b := New(newReport("web-abc"))
+
+	// The pod is replaced by a rollout of the deployment
+	r := newReport("web-def")
+	b.Apply(&r)
+	require.Equal(t, &report.BaselineComparison{New: 0, Existing: 1, Fixed: 0}, r.Baseline)
+	require.Empty(t, r.Results)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.WriteJUnit(buf, r))
+	require.NotContains(t, buf.String(), "<failure")
+}
+
+func TestFingerprint(t *testing.T) {
+	ref := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "default", Name: "debug"}
+	require.Equal(t, Fingerprint("ImagePullPolicyAlways", ref, "container debug"), Fingerprint("ImagePullPolicyAlways", ref, "container debug"))
+	require.NotEqual(t, Fingerprint("ImagePullPolicyAlways", ref, "container debug"), Fingerprint("ImagePullPolicyAlways", ref, "container sidecar"))
+	require.NotEqual(t, Fingerprint("ImagePullPolicyAlways", ref, ""), Fingerprint("WithoutController", ref, ""))
+}
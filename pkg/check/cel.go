@@ -0,0 +1,215 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+const (
+	listKindOverload          = "list_kind_string_string"
+	listKindNamespaceOverload = "list_kind_string_string_string"
+)
+
+// CELRuleFile is a file of custom rules using CEL expressions.
+type CELRuleFile struct {
+	Rules []CELRule `yaml:"rules"`
+}
+
+// CELRule is a custom rule which is evaluated with CEL expressions.
+type CELRule struct {
+	ID          string `yaml:"id"`
+	Severity    uint   `yaml:"severity"`
+	Description string `yaml:"description"`
+	Link        string `yaml:"link"`
+	// Kinds are the kinds of objects the rule is evaluated for, all objects if empty.
+	Kinds []string `yaml:"kinds"`
+	// Expression returns true if the object violates the rule.
+	Expression string `yaml:"expression"`
+	// Message optionally returns a string describing the violation.
+	Message string `yaml:"message"`
+}
+
+// LoadCELRules reads and compiles the custom rules in the files, keyed by the kind they are evaluated for.
+func LoadCELRules(paths ...string) (map[string][]Rule, error) {
+	env, err := newCELEnv()
+	if err != nil {
+		return nil, fmt.Errorf("could not create cel environment: %w", err)
+	}
+	rules := map[string][]Rule{}
+	ids := map[string]bool{}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read cel rules file: %w", err)
+		}
+		ruleFile := &CELRuleFile{}
+		if err := yaml.UnmarshalStrict(b, ruleFile); err != nil {
+			return nil, fmt.Errorf("could not unmarshal cel rules file %s: %w", path, err)
+		}
+		for i, celRule := range ruleFile.Rules {
+			rule, err := celRule.compile(env)
+			if err != nil {
+				return nil, fmt.Errorf("%s: rules[%d] (%s): %w", path, i, celRule.ID, err)
+			}
+			if ids[rule.ID] {
+				return nil, fmt.Errorf("%s: rules[%d]: duplicate rule id %s", path, i, rule.ID)
+			}
+			ids[rule.ID] = true
+			kinds := celRule.Kinds
+			if len(kinds) == 0 {
+				kinds = []string{"all"}
+			}
+			for _, kind := range kinds {
+				kind = strings.ToLower(kind)
+				rules[kind] = append(rules[kind], rule)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func newCELEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("rootOwner", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("owners", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		cel.Variable("edges", cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		// The implementation of listKind depends on the graph and is set when the program is created
+		cel.Function("listKind",
+			cel.Overload(listKindOverload, []*cel.Type{cel.StringType, cel.StringType}, cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+			cel.Overload(listKindNamespaceOverload, []*cel.Type{cel.StringType, cel.StringType, cel.StringType}, cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		),
+	)
+}
+
+func (r CELRule) compile(env *cel.Env) (Rule, error) {
+	rule := Rule{
+		ID:          r.ID,
+		Severity:    r.Severity,
+		Description: r.Description,
+		Link:        r.Link,
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	if r.Expression == "" {
+		return Rule{}, fmt.Errorf("expression cannot be empty")
+	}
+	expression, issues := env.Compile(r.Expression)
+	if issues != nil && issues.Err() != nil {
+		return Rule{}, fmt.Errorf("could not compile expression: %w", issues.Err())
+	}
+	if !expression.OutputType().IsAssignableType(cel.BoolType) {
+		return Rule{}, fmt.Errorf("expression has to return a bool, not %s", expression.OutputType())
+	}
+	var message *cel.Ast
+	if r.Message != "" {
+		message, issues = env.Compile(r.Message)
+		if issues != nil && issues.Err() != nil {
+			return Rule{}, fmt.Errorf("could not compile message: %w", issues.Err())
+		}
+		if !message.OutputType().IsAssignableType(cel.StringType) {
+			return Rule{}, fmt.Errorf("message has to return a string, not %s", message.OutputType())
+		}
+	}
+	rule.Evaluate = celEvaluate(env, expression, message)
+	return rule, nil
+}
+
+// celPrograms are the programs of a rule for a graph.
+type celPrograms struct {
+	expression cel.Program
+	message    cel.Program
+}
+
+// celProgramsKey is the key of the programs of a rule in the graph cache.
+type celProgramsKey struct {
+	expression *cel.Ast
+}
+
+// celEvaluate returns an evaluate function for the expressions. Programs are created and cached
+// for each graph, as the implementation of the graph functions depends on it.
+func celEvaluate(env *cel.Env, expression, message *cel.Ast) EvaluateFunction {
+	return func(ctx context.Context, node *graph.Node, g *graph.Graph) (bool, []string, error) {
+		cached, err := g.Cached(celProgramsKey{expression: expression}, func() (interface{}, error) {
+			programs := celPrograms{}
+			var err error
+			programs.expression, err = env.Program(expression, cel.Functions(celGraphFunctions(g)...))
+			if err != nil {
+				return nil, err
+			}
+			if message != nil {
+				programs.message, err = env.Program(message, cel.Functions(celGraphFunctions(g)...))
+				if err != nil {
+					return nil, err
+				}
+			}
+			return programs, nil
+		})
+		if err != nil {
+			return false, nil, err
+		}
+		programs := cached.(celPrograms)
+		exprPrg, msgPrg := programs.expression, programs.message
+
+		input := newNeighbourhood(node, g)
+		activation := map[string]interface{}{
+			"object":    input.Object,
+			"rootOwner": input.RootOwner,
+			"owners":    input.Owners,
+			"edges":     input.Edges,
+		}
+		out, _, err := exprPrg.Eval(activation)
+		if err != nil {
+			return false, nil, fmt.Errorf("could not evaluate expression for %s: %w", node.Reference.ID(), err)
+		}
+		violated, ok := out.Value().(bool)
+		if !ok {
+			return false, nil, fmt.Errorf("expression did not return a bool for %s", node.Reference.ID())
+		}
+		if !violated || msgPrg == nil {
+			return violated, nil, nil
+		}
+		out, _, err = msgPrg.Eval(activation)
+		if err != nil {
+			return false, nil, fmt.Errorf("could not evaluate message for %s: %w", node.Reference.ID(), err)
+		}
+		return true, []string{fmt.Sprint(out.Value())}, nil
+	}
+}
+
+func celGraphFunctions(g *graph.Graph) []*functions.Overload {
+	list := func(group, kind, namespace ref.Val) ref.Val {
+		gk := schema.GroupKind{Group: fmt.Sprint(group.Value()), Kind: fmt.Sprint(kind.Value())}
+		objects := []interface{}{}
+		for _, node := range g.ListGroupKind(gk, graph.ListOptions{Namespace: fmt.Sprint(namespace.Value())}) {
+			objects = append(objects, node.Unstructured.Object)
+		}
+		return types.NewDynamicList(types.DefaultTypeAdapter, objects)
+	}
+	return []*functions.Overload{
+		{
+			Operator: listKindOverload,
+			Binary: func(group, kind ref.Val) ref.Val {
+				return list(group, kind, types.String(""))
+			},
+		},
+		{
+			Operator: listKindNamespaceOverload,
+			Function: func(args ...ref.Val) ref.Val {
+				return list(args[0], args[1], args[2])
+			},
+		},
+	}
+}
@@ -0,0 +1,102 @@
+package check
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLoadCELRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(path, []byte(`
+rules:
+  - id: RequireTeamLabel
+    severity: 4
+    description: Pods must have a team label.
+    link: https://example.com
+    kinds: [Pod]
+    expression: '!has(object.metadata.labels) || !("team" in object.metadata.labels)'
+    message: '"missing team label on " + object.metadata.name'
+  - id: ServiceMissing
+    severity: 5
+    description: Namespace has no services.
+    link: https://example.com
+    expression: 'size(listKind("", "Service", object.metadata.namespace)) == 0'
+`), 0o600)
+	require.NoError(t, err)
+	rules, err := LoadCELRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules["pod"], 1)
+	require.Len(t, rules["all"], 1)
+
+	g := graph.NewGraph()
+	for _, obj := range []map[string]interface{}{
+		{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "foo", "namespace": "default", "uid": "1"}},
+		{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "bar", "namespace": "default", "uid": "2", "labels": map[string]interface{}{"team": "a"}}},
+		{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "baz", "namespace": "other", "uid": "3"}},
+	} {
+		require.NoError(t, g.AddUnstructuredNode(unstructured.Unstructured{Object: obj}))
+	}
+
+	violated, messages, err := rules["pod"][0].Evaluate(context.Background(), g.NodeByReferenceID("v1/Pod/default/foo"), g)
+	require.NoError(t, err)
+	require.True(t, violated)
+	require.Equal(t, []string{"missing team label on foo"}, messages)
+	violated, _, err = rules["pod"][0].Evaluate(context.Background(), g.NodeByReferenceID("v1/Pod/default/bar"), g)
+	require.NoError(t, err)
+	require.False(t, violated)
+
+	violated, _, err = rules["all"][0].Evaluate(context.Background(), g.NodeByReferenceID("v1/Pod/default/foo"), g)
+	require.NoError(t, err)
+	require.True(t, violated)
+	violated, _, err = rules["all"][0].Evaluate(context.Background(), g.NodeByReferenceID("v1/Service/other/baz"), g)
+	require.NoError(t, err)
+	require.False(t, violated)
+
+	// Graphs evaluated concurrently use their own programs
+	other := graph.NewGraph()
+	require.NoError(t, other.AddUnstructuredNode(unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "baz", "namespace": "default", "uid": "4"},
+	}}))
+	require.NoError(t, other.AddUnstructuredNode(unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1", "kind": "Pod", "metadata": map[string]interface{}{"name": "foo", "namespace": "default", "uid": "5"},
+	}}))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			violated, _, err := rules["all"][0].Evaluate(context.Background(), g.NodeByReferenceID("v1/Pod/default/foo"), g)
+			require.NoError(t, err)
+			require.True(t, violated)
+		}()
+		go func() {
+			defer wg.Done()
+			violated, _, err := rules["all"][0].Evaluate(context.Background(), other.NodeByReferenceID("v1/Pod/default/foo"), other)
+			require.NoError(t, err)
+			require.False(t, violated)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadCELRulesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(path, []byte(`
+rules:
+  - id: Invalid
+    severity: 4
+    description: Invalid rule.
+    link: https://example.com
+    expression: 'object.metadata.name + 1'
+`), 0o600)
+	require.NoError(t, err)
+	_, err = LoadCELRules(path)
+	require.EqualError(t, err, path+": rules[0] (Invalid): expression has to return a bool, not int")
+}
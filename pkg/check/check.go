@@ -2,73 +2,339 @@ package check
 
 import (
 	"context"
+	"fmt"
 	iofs "io/fs"
+	"sort"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/util/version"
+
 	"github.com/xenitab/kube-checker/pkg/graph"
 )
 
 type Checker struct {
-	rules        map[string][]Rule
-	deprecations map[string]Deprecation
+	rules                 map[string][]Rule
+	deprecations          map[string]Deprecation
+	deprecationsVersion   string
+	deprecationFiles      []string
+	deprecationComponents []string
+	targetVersion         *version.Version
+	config                *Config
+	requireIgnoreReason   bool
+}
+
+// Option configures optional behavior of the checker.
+type Option func(c *Checker) error
+
+// WithTargetVersion classifies Kubernetes deprecations by their status in the target version.
+func WithTargetVersion(targetVersion *version.Version) Option {
+	return func(c *Checker) error {
+		c.targetVersion = targetVersion
+		return nil
+	}
+}
+
+// WithDeprecationFiles merges the deprecations in the files on top of the embedded deprecations.
+func WithDeprecationFiles(paths ...string) Option {
+	return func(c *Checker) error {
+		c.deprecationFiles = append(c.deprecationFiles, paths...)
+		return nil
+	}
+}
+
+// WithDeprecationComponents only checks the deprecations of the components.
+func WithDeprecationComponents(components ...string) Option {
+	return func(c *Checker) error {
+		c.deprecationComponents = append(c.deprecationComponents, components...)
+		return nil
+	}
+}
+
+// WithRules adds custom rules keyed by the kind they are evaluated for, or "all" for every kind.
+func WithRules(rules map[string][]Rule) Option {
+	return func(c *Checker) error {
+		merged, err := MergeRules(c.rules, rules)
+		if err != nil {
+			return err
+		}
+		c.rules = merged
+		return nil
+	}
+}
+
+// MergeRules merges rules keyed by kind, rule IDs have to be unique.
+func MergeRules(a, b map[string][]Rule) (map[string][]Rule, error) {
+	ids := map[string]bool{}
+	for _, kindRules := range a {
+		for _, rule := range kindRules {
+			ids[rule.ID] = true
+		}
+	}
+	merged := map[string][]Rule{}
+	for kind, kindRules := range a {
+		merged[kind] = append(merged[kind], kindRules...)
+	}
+	for kind, kindRules := range b {
+		for _, rule := range kindRules {
+			if ids[rule.ID] {
+				return nil, fmt.Errorf("rule id %s is already used", rule.ID)
+			}
+		}
+		merged[kind] = append(merged[kind], kindRules...)
+	}
+	return merged, nil
 }
 
-func NewChecker(fs iofs.FS) (*Checker, error) {
-	deprecations, err := loadDeprecations(fs)
+// WithConfig overrides the rules with the config.
+func WithConfig(cfg *Config) Option {
+	return func(c *Checker) error {
+		if err := cfg.validate(c.rules); err != nil {
+			return fmt.Errorf("invalid config: %w", err)
+		}
+		c.config = cfg
+		return nil
+	}
+}
+
+func NewChecker(fs iofs.FS, opts ...Option) (*Checker, error) {
+	c := &Checker{
+		rules: getRules(),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	for kind, kindRules := range c.rules {
+		enabledRules := []Rule{}
+		for _, rule := range kindRules {
+			c.config.apply(&rule)
+			if rule.Disabled {
+				continue
+			}
+			enabledRules = append(enabledRules, rule)
+		}
+		c.rules[kind] = enabledRules
+	}
+	deprecations, deprecationsVersion, err := loadDeprecations(fs, c.deprecationFiles, c.deprecationComponents)
 	if err != nil {
 		return nil, err
 	}
-	rules := getRules()
-	return &Checker{
-		rules:        rules,
-		deprecations: deprecations,
-	}, nil
+	c.deprecations = deprecations
+	c.deprecationsVersion = deprecationsVersion
+	return c, nil
+}
+
+// Rules returns all rules evaluated by the checker sorted by ID, deprecation rules are not included.
+func (c *Checker) Rules() []Rule {
+	rules := []Rule{}
+	for _, kindRules := range c.rules {
+		rules = append(rules, kindRules...)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// TargetVersion returns the version deprecations are classified against, empty if there is no target version.
+func (c *Checker) TargetVersion() string {
+	if c.targetVersion == nil {
+		return ""
+	}
+	return "v" + c.targetVersion.String()
+}
+
+// DeprecationsVersion returns a hash identifying the deprecation data used by the checker.
+func (c *Checker) DeprecationsVersion() string {
+	return c.deprecationsVersion
+}
+
+// hasDeprecations returns true if there are any deprecated api versions for the kind.
+func (c *Checker) hasDeprecations(kind string) bool {
+	for _, deprecation := range c.deprecations {
+		if deprecation.Kind == kind {
+			return true
+		}
+	}
+	return false
 }
 
+// Evaluate evaluates all rules for every node in the graph. A rule result is
+// returned for every rule which has been evaluated, even if there are no violations.
 func (c *Checker) Evaluate(g *graph.Graph) (map[string]*RuleResult, error) {
 	ruleResults := map[string]*RuleResult{}
 	err := g.Iterate(func(node *graph.Node) error {
-		// Check for api version deprecation
-		ruleResult := deprecatedApiVersion(c.deprecations, node)
-		if ruleResult != nil {
-			if _, ok := ruleResults[ruleResult.Rule.ID]; !ok {
-				ruleResults[ruleResult.Rule.ID] = ruleResult
-			} else {
-				ruleResults[ruleResult.Rule.ID].Violations = append(ruleResults[ruleResult.Rule.ID].Violations, ruleResult.Violations...)
-			}
-		}
+		return c.evaluateNode(ruleResults, node, g)
+	})
+	if err != nil {
+		return nil, err
+	}
+	helmResults, err := c.EvaluateHelmReleases(g)
+	if err != nil {
+		return nil, err
+	}
+	return MergeResults(ruleResults, helmResults), nil
+}
 
-		// Evaluate rules for each kind
-		kindRules := c.rules[strings.ToLower(node.Reference.Kind)]
-		kindRules = append(kindRules, c.rules["all"]...)
-		for _, rule := range kindRules {
-			hasViolated, messages, err := rule.Evaluate(context.Background(), node, g)
+// EvaluateNode evaluates all rules for a single node in the graph.
+func (c *Checker) EvaluateNode(g *graph.Graph, node *graph.Node) (map[string]*RuleResult, error) {
+	ruleResults := map[string]*RuleResult{}
+	err := c.evaluateNode(ruleResults, node, g)
+	if err != nil {
+		return nil, err
+	}
+	return ruleResults, nil
+}
+
+// EvaluateHelmReleases checks the objects in Helm releases for api version deprecations,
+// as they will cause upgrades of the release to fail once the api version is removed.
+func (c *Checker) EvaluateHelmReleases(g *graph.Graph) (map[string]*RuleResult, error) {
+	ruleResults := map[string]*RuleResult{}
+	for _, release := range g.HelmReleases() {
+		for _, u := range release.Objects {
+			node, err := graph.NewNode(u)
 			if err != nil {
-				return err
-			}
-			if !hasViolated {
-				continue
+				return nil, fmt.Errorf("could not parse object in helm release %s: %w", release.Reference().ID(), err)
 			}
-			rootNode := g.FindRootOwner(node)
-			violation := Violation{
-				Reference: rootNode.Reference,
-				Message:   strings.Join(messages, ", "),
+			c.evaluateDeprecation(ruleResults, node, nil, release)
+		}
+	}
+	return ruleResults, nil
+}
+
+func (c *Checker) evaluateNode(ruleResults map[string]*RuleResult, node *graph.Node, g *graph.Graph) error {
+	rootNode := g.FindRootOwner(node)
+
+	// Check for api version deprecation
+	c.evaluateDeprecation(ruleResults, node, rootNode, nil)
+
+	// Evaluate rules for each kind
+	kindRules := c.rules[strings.ToLower(node.Reference.Kind)]
+	kindRules = append(kindRules, c.rules["all"]...)
+	for _, rule := range kindRules {
+		if !rule.inScope(node) {
+			continue
+		}
+		ctx := withParameters(context.Background(), rule.Parameters)
+		hasViolated, messages, err := rule.Evaluate(ctx, node, g)
+		if err != nil {
+			return err
+		}
+		result, ok := ruleResults[rule.ID]
+		if !ok {
+			result = &RuleResult{
+				Rule:       rule,
+				Violations: []Violation{},
 			}
-			if _, ok := ruleResults[rule.ID]; !ok {
-				ruleResults[rule.ID] = &RuleResult{
-					Rule:       rule,
-					Violations: []Violation{violation},
+			ruleResults[rule.ID] = result
+		}
+		evaluation := Evaluation{
+			Object:    node.Reference,
+			RootOwner: rootNode.Reference,
+			Violated:  hasViolated,
+			Message:   strings.Join(messages, ", "),
+		}
+		if !hasViolated {
+			result.Evaluations = append(result.Evaluations, evaluation)
+			continue
+		}
+		violation := Violation{
+			Reference:   rootNode.Reference,
+			Object:      node.Reference,
+			Source:      node.Source,
+			Message:     strings.Join(messages, ", "),
+			Suppression: c.suppression(rule.ID, node, rootNode),
+		}
+		evaluation.Suppressed = violation.Suppression != nil
+		result.Evaluations = append(result.Evaluations, evaluation)
+		result.AddViolation(violation)
+	}
+	return nil
+}
+
+// MergeResults merges rule results of different nodes into a single result per rule.
+func MergeResults(results ...map[string]*RuleResult) map[string]*RuleResult {
+	merged := map[string]*RuleResult{}
+	for _, ruleResults := range results {
+		for id, ruleResult := range ruleResults {
+			result, ok := merged[id]
+			if !ok {
+				result = &RuleResult{
+					Rule:       ruleResult.Rule,
+					Violations: []Violation{},
 				}
-				continue
+				merged[id] = result
 			}
-			ruleResults[rule.ID].AddViolation(violation)
+			result.merge(ruleResult)
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
-	return ruleResults, nil
+	return merged
+}
+
+// evaluateDeprecation checks the node for deprecated api versions and adds the result.
+// Violations of objects in a Helm release are reported against the release.
+func (c *Checker) evaluateDeprecation(ruleResults map[string]*RuleResult, node, rootNode *graph.Node, release *graph.HelmRelease) {
+	if !c.hasDeprecations(node.Reference.Kind) {
+		return
+	}
+	ruleResult := deprecatedApiVersion(c.deprecations, c.targetVersion, node)
+	if ruleResult == nil {
+		ruleResult = &RuleResult{
+			Rule: Rule{
+				ID:          deprecatedApiVersionID(node),
+				Severity:    10,
+				Description: "Resource is using a deprecated api version.",
+			},
+		}
+	}
+	c.config.apply(&ruleResult.Rule)
+	if ruleResult.Rule.Disabled || !ruleResult.Rule.inScope(node) {
+		return
+	}
+	if release != nil {
+		for i := range ruleResult.Violations {
+			ruleResult.Violations[i].Reference = release.Reference()
+			ruleResult.Violations[i].Message = fmt.Sprintf("in revision %d of helm release with chart %s version %s", release.Revision, release.Chart, release.ChartVersion)
+		}
+	}
+	suppression := c.suppression(ruleResult.Rule.ID, node, rootNode)
+	result, ok := ruleResults[ruleResult.Rule.ID]
+	if !ok {
+		result = &RuleResult{
+			Rule:       ruleResult.Rule,
+			Violations: []Violation{},
+		}
+		ruleResults[ruleResult.Rule.ID] = result
+	}
+	// Rules without violations only have a generic description
+	if len(ruleResult.Violations) > 0 && len(result.Violations) == 0 && len(result.Suppressed) == 0 {
+		result.Rule = ruleResult.Rule
+	}
+	if suppression != nil {
+		for _, violation := range ruleResult.Violations {
+			violation.Suppression = suppression
+			result.Suppressed = append(result.Suppressed, violation)
+		}
+	} else {
+		result.Violations = append(result.Violations, ruleResult.Violations...)
+	}
+	// Objects in Helm releases are not nodes in the graph and have no root node, they are owned by the release
+	evaluation := Evaluation{
+		Object:     node.Reference,
+		Violated:   len(ruleResult.Violations) > 0,
+		Suppressed: len(ruleResult.Violations) > 0 && suppression != nil,
+	}
+	if release != nil {
+		evaluation.RootOwner = release.Reference()
+	} else {
+		evaluation.RootOwner = rootNode.Reference
+	}
+	if len(ruleResult.Violations) > 0 {
+		evaluation.RootOwner = ruleResult.Violations[0].Reference
+		evaluation.Message = ruleResult.Violations[0].Message
+	}
+	result.Evaluations = append(result.Evaluations, evaluation)
 }
 
 // cert manager annotations
@@ -0,0 +1,165 @@
+package check
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+var testDeprecations = fstest.MapFS{
+	"deprecated-versions.yaml": &fstest.MapFile{Data: []byte(`
+- component: kubernetes
+  apiVersion: policy/v1beta1
+  kind: PodDisruptionBudget
+  newApiVersion: policy/v1
+  deprecatedIn: v1.21
+  removedIn: v1.25
+  link: https://kubernetes.io
+`)},
+}
+
+// newHelmReleaseSecret returns the secret Helm stores the deployed release with the manifest in.
+func newHelmReleaseSecret(t *testing.T, name string, revision int, manifest string) unstructured.Unstructured {
+	t.Helper()
+	b, err := json.Marshal(map[string]interface{}{
+		"name":      name,
+		"namespace": "default",
+		"version":   revision,
+		"info":      map[string]interface{}{"status": "deployed"},
+		"chart":     map[string]interface{}{"metadata": map[string]interface{}{"name": name, "version": "1.0.0"}},
+		"manifest":  manifest,
+	})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err = w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	release := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	u := unstructured.Unstructured{Object: map[string]interface{}{
+		"type": "helm.sh/release.v1",
+		"data": map[string]interface{}{"release": base64.StdEncoding.EncodeToString([]byte(release))},
+	}}
+	u.SetAPIVersion("v1")
+	u.SetKind("Secret")
+	u.SetNamespace("default")
+	u.SetName(fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision))
+	u.SetUID(types.UID("release-" + name))
+	u.SetLabels(map[string]string{"owner": "helm", "name": name, "status": "deployed", "version": fmt.Sprint(revision)})
+	return u
+}
+
+func TestEvaluateHelmReleases(t *testing.T) {
+	g := graph.NewGraph()
+	require.NoError(t, g.AddUnstructuredNode(newHelmReleaseSecret(t, "app", 2, `---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: app
+`)))
+	checker, err := NewChecker(testDeprecations)
+	require.NoError(t, err)
+
+	ruleResults, err := checker.Evaluate(g)
+	require.NoError(t, err)
+	ruleResult := ruleResults["APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget"]
+	require.NotNil(t, ruleResult)
+	require.Len(t, ruleResult.Evaluations, 1)
+	release := graph.ObjectReference{ApiVersion: "helm.sh/release.v1", Kind: "HelmRelease", Namespace: "default", Name: "app"}
+	require.Equal(t, release, ruleResult.Evaluations[0].RootOwner)
+}
+
+func TestEvaluateHelmReleasesViolations(t *testing.T) {
+	g := graph.NewGraph()
+	require.NoError(t, g.AddUnstructuredNode(newHelmReleaseSecret(t, "app", 2, `---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: app
+`)))
+	require.NoError(t, g.AddUnstructuredNode(newHelmReleaseSecret(t, "legacy", 1, `---
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: legacy
+  annotations:
+    kube-checker.xenit.io/ignore: APIVersion*
+    kube-checker.xenit.io/ignore-reason: removed with the next release
+`)))
+	app := graph.ObjectReference{ApiVersion: "helm.sh/release.v1", Kind: "HelmRelease", Namespace: "default", Name: "app"}
+	legacy := graph.ObjectReference{ApiVersion: "helm.sh/release.v1", Kind: "HelmRelease", Namespace: "default", Name: "legacy"}
+
+	for _, tt := range []struct {
+		targetVersion string
+		ruleID        string
+		severity      uint
+	}{
+		{ruleID: "APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", severity: 10},
+		{targetVersion: "v1.22.0", ruleID: "APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", severity: 5},
+		{targetVersion: "v1.25.0", ruleID: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", severity: 10},
+	} {
+		t.Run(tt.ruleID+tt.targetVersion, func(t *testing.T) {
+			opts := []Option{}
+			if tt.targetVersion != "" {
+				opts = append(opts, WithTargetVersion(version.MustParseGeneric(tt.targetVersion)))
+			}
+			checker, err := NewChecker(testDeprecations, opts...)
+			require.NoError(t, err)
+			ruleResults, err := checker.EvaluateHelmReleases(g)
+			require.NoError(t, err)
+
+			ruleResult := ruleResults[tt.ruleID]
+			require.NotNil(t, ruleResult)
+			require.Equal(t, tt.severity, ruleResult.Rule.Severity)
+			require.Len(t, ruleResult.Violations, 1)
+			violation := ruleResult.Violations[0]
+			require.Equal(t, app, violation.Reference)
+			// The object is as written in the manifest of the release, which does not contain the namespace
+			require.Equal(t, graph.ObjectReference{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Name: "app"}, violation.Object)
+			require.Equal(t, "in revision 2 of helm release with chart app version 1.0.0", violation.Message)
+
+			// Objects in the release are suppressed by their own annotations
+			require.Len(t, ruleResult.Suppressed, 1)
+			require.Equal(t, legacy, ruleResult.Suppressed[0].Reference)
+			require.Equal(t, "removed with the next release", ruleResult.Suppressed[0].Suppression.Reason)
+			require.Len(t, ruleResult.Evaluations, 2)
+			for _, evaluation := range ruleResult.Evaluations {
+				require.True(t, evaluation.Violated)
+				require.Equal(t, evaluation.RootOwner == legacy, evaluation.Suppressed)
+			}
+		})
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	a := map[string][]Rule{
+		"Pod": {{ID: "PodRule"}},
+	}
+	b := map[string][]Rule{
+		"Pod": {{ID: "OtherPodRule"}},
+		"all": {{ID: "AllRule"}},
+	}
+	merged, err := MergeRules(a, b)
+	require.NoError(t, err)
+	require.Equal(t, []Rule{{ID: "PodRule"}, {ID: "OtherPodRule"}}, merged["Pod"])
+	require.Equal(t, []Rule{{ID: "AllRule"}}, merged["all"])
+	require.Len(t, a["Pod"], 1)
+
+	_, err = MergeRules(a, map[string][]Rule{"Service": {{ID: "PodRule"}}})
+	require.EqualError(t, err, "rule id PodRule is already used")
+
+	_, err = NewChecker(testDeprecations, WithRules(map[string][]Rule{"Service": {{ID: "PodRule"}}}), WithRules(a))
+	require.EqualError(t, err, "rule id PodRule is already used")
+}
@@ -0,0 +1,217 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// deprecationRulePrefixes are the ID prefixes of the rules created for deprecated api versions.
+var deprecationRulePrefixes = []string{"APIVersionDeprecated", "APIVersionRemoved", "APIVersionPendingDeprecation"}
+
+// Config configures which rules are evaluated and how.
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig overrides the rules with an ID matching the pattern in ID. Later
+// entries take precedence over earlier entries matching the same rule.
+type RuleConfig struct {
+	// ID is a rule ID or a pattern matched with MatchRuleID.
+	ID         string                 `yaml:"id"`
+	Enabled    *bool                  `yaml:"enabled"`
+	Severity   *uint                  `yaml:"severity"`
+	Parameters map[string]interface{} `yaml:"parameters"`
+	// Namespaces limits the rule to objects in the namespaces.
+	Namespaces []string `yaml:"namespaces"`
+	// Selector limits the rule to objects with labels matching the label selector.
+	Selector string `yaml:"selector"`
+
+	selector labels.Selector
+}
+
+// LoadConfig reads and validates the rule configuration file. Custom rules have
+// to be passed to allow the configuration to refer to them.
+func LoadConfig(path string, customRules map[string][]Rule) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("could not unmarshal config file %s: %w", path, err)
+	}
+	rules := getRules()
+	for kind, kindRules := range customRules {
+		rules[kind] = append(rules[kind], kindRules...)
+	}
+	if err := cfg.validate(rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate(kindRules map[string][]Rule) error {
+	rules := []Rule{}
+	for _, r := range kindRules {
+		rules = append(rules, r...)
+	}
+	for i := range cfg.Rules {
+		ruleCfg := &cfg.Rules[i]
+		if err := ruleCfg.validate(rules); err != nil {
+			return fmt.Errorf("rules[%d] (%s): %w", i, ruleCfg.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *RuleConfig) validate(rules []Rule) error {
+	if r.ID == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	if _, err := MatchRuleID(r.ID, ""); err != nil {
+		return fmt.Errorf("invalid id pattern: %w", err)
+	}
+	if r.Severity != nil && (*r.Severity == 0 || *r.Severity > 10) {
+		return fmt.Errorf("severity has to be between 1 and 10")
+	}
+	selector, err := labels.Parse(r.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	r.selector = selector
+
+	matched := []Rule{}
+	for _, rule := range rules {
+		if r.matches(rule.ID) {
+			matched = append(matched, rule)
+		}
+	}
+	if len(matched) == 0 && !r.matchesDeprecation() {
+		return fmt.Errorf("id does not match any rule")
+	}
+	for name, value := range r.Parameters {
+		found := false
+		for _, rule := range matched {
+			defaultValue, ok := rule.Parameters[name]
+			if !ok {
+				continue
+			}
+			found = true
+			if reflect.TypeOf(value) != reflect.TypeOf(defaultValue) {
+				return fmt.Errorf("parameter %s of rule %s has to be of type %T", name, rule.ID, defaultValue)
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	return nil
+}
+
+func (r *RuleConfig) matches(id string) bool {
+	ok, _ := MatchRuleID(r.ID, id)
+	return ok
+}
+
+// matchesDeprecation returns true if the pattern can match rules of deprecated api versions,
+// which cannot be listed as they depend on the objects being checked.
+func (r *RuleConfig) matchesDeprecation() bool {
+	literal := r.ID
+	if i := strings.IndexAny(literal, "*?[\\"); i >= 0 {
+		literal = literal[:i]
+	}
+	for _, prefix := range deprecationRulePrefixes {
+		if strings.HasPrefix(prefix+"/", literal) || strings.HasPrefix(literal, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// apply overrides the rule with all rule configs matching the rule ID.
+func (cfg *Config) apply(rule *Rule) {
+	if cfg == nil {
+		return
+	}
+	for _, ruleCfg := range cfg.Rules {
+		if !ruleCfg.matches(rule.ID) {
+			continue
+		}
+		if ruleCfg.Enabled != nil {
+			rule.Disabled = !*ruleCfg.Enabled
+		}
+		if ruleCfg.Severity != nil {
+			rule.Severity = *ruleCfg.Severity
+		}
+		if len(ruleCfg.Parameters) > 0 {
+			parameters := map[string]interface{}{}
+			for k, v := range rule.Parameters {
+				parameters[k] = v
+			}
+			for k, v := range ruleCfg.Parameters {
+				if _, ok := parameters[k]; ok {
+					parameters[k] = v
+				}
+			}
+			rule.Parameters = parameters
+		}
+		if len(ruleCfg.Namespaces) > 0 {
+			rule.Namespaces = ruleCfg.Namespaces
+		}
+		if ruleCfg.Selector != "" {
+			rule.Selector = ruleCfg.selector
+		}
+	}
+}
+
+type parametersKey struct{}
+
+// withParameters returns a context passing the rule parameters to the evaluate function.
+func withParameters(ctx context.Context, parameters map[string]interface{}) context.Context {
+	return context.WithValue(ctx, parametersKey{}, parameters)
+}
+
+func parameter(ctx context.Context, name string) interface{} {
+	parameters, _ := ctx.Value(parametersKey{}).(map[string]interface{})
+	value, ok := parameters[name]
+	if !ok {
+		panic(fmt.Sprintf("rule parameter %s is not defined", name))
+	}
+	return value
+}
+
+func intParameter(ctx context.Context, name string) int {
+	return parameter(ctx, name).(int)
+}
+
+func stringParameter(ctx context.Context, name string) string {
+	return parameter(ctx, name).(string)
+}
+
+// inScope returns true if the rule should be evaluated for the node.
+func (r *Rule) inScope(node *graph.Node) bool {
+	if len(r.Namespaces) > 0 {
+		found := false
+		for _, namespace := range r.Namespaces {
+			if node.Reference.Namespace == namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Selector != nil && !r.Selector.Matches(labels.Set(node.Unstructured.GetLabels())) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+rules:
+  - id: PremiumStorage
+    enabled: true
+  - id: ReadinessInitialDelayHigh
+    severity: 5
+    parameters:
+      maxInitialDelaySeconds: 60
+  - id: "APIVersionRemoved/*"
+    namespaces: [default]
+    selector: app=web
+`,
+		},
+		{
+			name:   "unknown rule",
+			config: "rules:\n  - id: Foo\n",
+			err:    "rules[0] (Foo): id does not match any rule",
+		},
+		{
+			name:   "invalid severity",
+			config: "rules:\n  - id: NoTLS\n    severity: 11\n",
+			err:    "rules[0] (NoTLS): severity has to be between 1 and 10",
+		},
+		{
+			name:   "unknown parameter",
+			config: "rules:\n  - id: NoTLS\n    parameters:\n      foo: 1\n",
+			err:    "rules[0] (NoTLS): unknown parameter foo",
+		},
+		{
+			name:   "parameter type",
+			config: "rules:\n  - id: ReadinessInitialDelayHigh\n    parameters:\n      maxInitialDelaySeconds: foo\n",
+			err:    "rules[0] (ReadinessInitialDelayHigh): parameter maxInitialDelaySeconds of rule ReadinessInitialDelayHigh has to be of type int",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(path, []byte(c.config), 0o600)
+			require.NoError(t, err)
+			_, err = LoadConfig(path, nil)
+			if c.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, path+": "+c.err)
+		})
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+rules:
+  - id: "*"
+    severity: 2
+  - id: ReadinessInitialDelayHigh
+    parameters:
+      maxInitialDelaySeconds: 60
+    namespaces: [default]
+    selector: app=web
+`), 0o600)
+	require.NoError(t, err)
+	cfg, err := LoadConfig(path, nil)
+	require.NoError(t, err)
+
+	rule := Rule{
+		ID:       "ReadinessInitialDelayHigh",
+		Severity: 3,
+		Parameters: map[string]interface{}{
+			"maxInitialDelaySeconds": 30,
+		},
+	}
+	cfg.apply(&rule)
+	require.Equal(t, uint(2), rule.Severity)
+	require.Equal(t, 60, rule.Parameters["maxInitialDelaySeconds"])
+
+	newNode := func(namespace string, labels map[string]string) *graph.Node {
+		u := unstructured.Unstructured{}
+		u.SetLabels(labels)
+		return &graph.Node{
+			Unstructured: u,
+			Reference:    graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: namespace, Name: "foo"},
+		}
+	}
+	require.True(t, rule.inScope(newNode("default", map[string]string{"app": "web"})))
+	require.False(t, rule.inScope(newNode("default", nil)))
+	require.False(t, rule.inScope(newNode("kube-system", map[string]string{"app": "web"})))
+}
@@ -9,9 +9,13 @@ import (
 	"github.com/xenitab/kube-checker/pkg/graph"
 )
 
-func daemonsetOnAllNodes(ctx context.Context, node *graph.Node, graph *graph.Graph) (bool, []string, error) {
+func daemonsetOnAllNodes(ctx context.Context, node *graph.Node, g *graph.Graph) (bool, []string, error) {
 	ds := node.Object.(*appsv1.DaemonSet)
-	nodeCount := len(graph.List(schema.GroupVersionKind{Version: "v1", Kind: "Node"}))
+	nodeCount := len(g.List(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, graph.ListOptions{}))
+	// Nodes are not known when scoped to a namespace or reading manifests
+	if nodeCount == 0 {
+		return false, nil, nil
+	}
 	if ds.Status.DesiredNumberScheduled == int32(nodeCount) {
 		return false, nil, nil
 	}
@@ -1,14 +1,20 @@
 package check
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	iofs "io/fs"
+	"os"
 	"strings"
 
 	"github.com/xenitab/kube-checker/pkg/graph"
 	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
+const kubernetesComponent = "kubernetes"
+
 type Deprecation struct {
 	Component string `yaml:"component"`
 
@@ -23,54 +29,199 @@ type Deprecation struct {
 	Link        string `yaml:"link"`
 }
 
-func loadDeprecations(fs iofs.FS) (map[string]Deprecation, error) {
-	b, err := iofs.ReadFile(fs, "deprecated-versions.yaml")
-	if err != nil {
-		return nil, fmt.Errorf("could not read deprecated versions file: %w", err)
+const embeddedDeprecationsFile = "deprecated-versions.yaml"
+
+// loadDeprecations returns the embedded deprecations merged with the deprecations in the files,
+// together with a hash identifying the deprecation data. Entries in later files override entries
+// with the same api version and kind in earlier ones. If components is not empty only the
+// deprecations of the listed components are returned.
+func loadDeprecations(fs iofs.FS, paths []string, components []string) (map[string]Deprecation, string, error) {
+	hash := sha256.New()
+	deprecationMap := map[string]Deprecation{}
+	addFile := func(name string, b []byte) error {
+		hash.Write(b)
+		deprecations, err := parseDeprecations(name, b)
+		if err != nil {
+			return err
+		}
+		for key, deprecation := range deprecations {
+			deprecationMap[key] = deprecation
+		}
+		return nil
 	}
-	deprecations := &[]Deprecation{}
-	err = yaml.Unmarshal(b, deprecations)
+
+	b, err := iofs.ReadFile(fs, embeddedDeprecationsFile)
 	if err != nil {
-		return nil, fmt.Errorf("could not unmarshal deprecated versions file: %w", err)
+		return nil, "", fmt.Errorf("could not read deprecated versions file: %w", err)
+	}
+	if err := addFile(embeddedDeprecationsFile, b); err != nil {
+		return nil, "", err
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not read deprecated versions file: %w", err)
+		}
+		if err := addFile(path, b); err != nil {
+			return nil, "", err
+		}
 	}
 
-	deprecationMap := map[string]Deprecation{}
-	for _, deprecation := range *deprecations {
-		if deprecation.Link == "" {
-			return nil, fmt.Errorf("link cannot be empty")
+	if len(components) > 0 {
+		knownComponents := map[string]bool{}
+		for _, deprecation := range deprecationMap {
+			knownComponents[deprecation.Component] = true
 		}
-		if deprecation.Kind == "" {
-			return nil, fmt.Errorf("kind cannot be empty")
+		filter := map[string]bool{}
+		for _, component := range components {
+			if !knownComponents[component] {
+				return nil, "", fmt.Errorf("unknown deprecation component: %s", component)
+			}
+			filter[component] = true
+			hash.Write([]byte(component))
 		}
-		if deprecation.ApiVersion == deprecation.NewApiVersion {
-			return nil, fmt.Errorf("deprecated api version %s and new apiversion %s cannot be the same", deprecation.ApiVersion, deprecation.NewApiVersion)
+		for key, deprecation := range deprecationMap {
+			if !filter[deprecation.Component] {
+				delete(deprecationMap, key)
+			}
 		}
+	}
+	return deprecationMap, hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// parseDeprecations parses and validates the deprecations in a file, keyed by api version and kind.
+func parseDeprecations(name string, b []byte) (map[string]Deprecation, error) {
+	deprecations := &[]Deprecation{}
+	err := yaml.Unmarshal(b, deprecations)
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshal deprecated versions file %s: %w", name, err)
+	}
+
+	deprecationMap := map[string]Deprecation{}
+	for i, deprecation := range *deprecations {
 		key := strings.Join([]string{deprecation.ApiVersion, deprecation.Kind}, "/")
+		if err := deprecation.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: entry %d (%s): %w", name, i, key, err)
+		}
 		if _, ok := deprecationMap[key]; ok {
-			return nil, fmt.Errorf("duplicate key found: %s", key)
+			return nil, fmt.Errorf("%s: entry %d: duplicate key found: %s", name, i, key)
 		}
 		deprecationMap[key] = deprecation
 	}
 	return deprecationMap, nil
 }
 
-func deprecatedApiVersion(deprecations map[string]Deprecation, node *graph.Node) *RuleResult {
+// Validate returns an error if the deprecation is not complete.
+func (d Deprecation) Validate() error {
+	if d.Component == "" {
+		return fmt.Errorf("component cannot be empty")
+	}
+	if d.ApiVersion == "" {
+		return fmt.Errorf("api version cannot be empty")
+	}
+	if d.Link == "" {
+		return fmt.Errorf("link cannot be empty")
+	}
+	if d.Kind == "" {
+		return fmt.Errorf("kind cannot be empty")
+	}
+	if _, err := version.ParseGeneric(d.DeprecatedIn); err != nil {
+		return fmt.Errorf("deprecated in version %q is not valid: %w", d.DeprecatedIn, err)
+	}
+	if d.RemovedIn != "" {
+		if _, err := version.ParseGeneric(d.RemovedIn); err != nil {
+			return fmt.Errorf("removed in version %q is not valid: %w", d.RemovedIn, err)
+		}
+	}
+	if d.ApiVersion == d.NewApiVersion {
+		return fmt.Errorf("deprecated api version %s and new apiversion %s cannot be the same", d.ApiVersion, d.NewApiVersion)
+	}
+	return nil
+}
+
+// DeprecationStatus describes how a deprecation affects a target Kubernetes version.
+type DeprecationStatus string
+
+const (
+	// DeprecationStatusRemoved means the api version is removed in or before the target version.
+	DeprecationStatusRemoved DeprecationStatus = "Removed"
+	// DeprecationStatusDeprecated means the api version is deprecated but still served in the target version.
+	DeprecationStatusDeprecated DeprecationStatus = "Deprecated"
+	// DeprecationStatusPending means the api version is not yet deprecated in the target version.
+	DeprecationStatusPending DeprecationStatus = "Pending"
+)
+
+// Status returns the status of the deprecation in the target Kubernetes version.
+func (d Deprecation) Status(target *version.Version) (DeprecationStatus, error) {
+	if d.RemovedIn != "" {
+		removedIn, err := version.ParseGeneric(d.RemovedIn)
+		if err != nil {
+			return "", err
+		}
+		if target.AtLeast(removedIn) {
+			return DeprecationStatusRemoved, nil
+		}
+	}
+	deprecatedIn, err := version.ParseGeneric(d.DeprecatedIn)
+	if err != nil {
+		return "", err
+	}
+	if target.AtLeast(deprecatedIn) {
+		return DeprecationStatusDeprecated, nil
+	}
+	return DeprecationStatusPending, nil
+}
+
+func deprecatedApiVersionID(node *graph.Node) string {
+	return fmt.Sprintf("APIVersionDeprecated/%s", node.Reference.GVK())
+}
+
+// deprecatedApiVersion checks if the node uses a deprecated api version. If a target version is
+// set Kubernetes deprecations are classified by their status in the target version, which decides
+// the rule ID and severity. Other components are versioned independently of Kubernetes so their
+// deprecations are always reported as deprecated.
+func deprecatedApiVersion(deprecations map[string]Deprecation, target *version.Version, node *graph.Node) *RuleResult {
+	// Objects read from manifests do not have managed fields so the object api version is checked as well
+	apiVersions := []string{node.Reference.ApiVersion}
 	for _, mf := range node.Unstructured.GetManagedFields() {
-		key := strings.Join([]string{mf.APIVersion, node.Reference.Kind}, "/")
+		apiVersions = append(apiVersions, mf.APIVersion)
+	}
+	for _, apiVersion := range apiVersions {
+		key := strings.Join([]string{apiVersion, node.Reference.Kind}, "/")
 		deprecation, ok := deprecations[key]
 		if !ok {
 			continue
 		}
+		rule := Rule{
+			ID:          deprecatedApiVersionID(node),
+			Severity:    10,
+			Description: fmt.Sprintf("Api version %q has been deprecated since version %s and will be removed in %s, please switch to %q", deprecation.ApiVersion, deprecation.DeprecatedIn, deprecation.RemovedIn, deprecation.NewApiVersion),
+			Link:        deprecation.Link,
+			Deprecation: &deprecation,
+		}
+		if target != nil && deprecation.Component == kubernetesComponent {
+			// Versions are validated when loading the deprecations
+			status, _ := deprecation.Status(target)
+			rule.DeprecationStatus = status
+			switch status {
+			case DeprecationStatusRemoved:
+				rule.ID = fmt.Sprintf("APIVersionRemoved/%s", node.Reference.GVK())
+				rule.Description = fmt.Sprintf("Api version %q is removed in version %s which is not after the target version %s, please switch to %q", deprecation.ApiVersion, deprecation.RemovedIn, target, deprecation.NewApiVersion)
+			case DeprecationStatusDeprecated:
+				rule.Severity = 5
+			case DeprecationStatusPending:
+				rule.ID = fmt.Sprintf("APIVersionPendingDeprecation/%s", node.Reference.GVK())
+				rule.Severity = 1
+				rule.Description = fmt.Sprintf("Api version %q will be deprecated in version %s which is after the target version %s, please switch to %q", deprecation.ApiVersion, deprecation.DeprecatedIn, target, deprecation.NewApiVersion)
+			}
+		}
 		return &RuleResult{
-			Rule: Rule{
-				ID:          fmt.Sprintf("APIVersionDeprecated/%s", node.Reference.GVK()),
-				Severity:    10,
-				Description: fmt.Sprintf("Api version %q has been deprecated since version %s and will be removed in %s, please switch to %q", deprecation.ApiVersion, deprecation.DeprecatedIn, deprecation.RemovedIn, deprecation.NewApiVersion),
-				Link:        deprecation.Link,
-			},
+			Rule: rule,
 			Violations: []Violation{
 				{
 					Reference: node.Reference,
+					Object:    node.Reference,
+					Source:    node.Source,
 				},
 			},
 		}
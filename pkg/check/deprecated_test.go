@@ -1,11 +1,15 @@
 package check
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 	"github.com/xenitab/kube-checker/pkg/graph"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/version"
 )
 
 func TestDeprecatedApiVersionBasic(t *testing.T) {
@@ -36,7 +40,7 @@ func TestDeprecatedApiVersionBasic(t *testing.T) {
 			NewApiVersion: "v1",
 		},
 	}
-	ruleResult := deprecatedApiVersion(deprecations, node)
+	ruleResult := deprecatedApiVersion(deprecations, nil, node)
 	require.NotNil(t, ruleResult)
 	require.Equal(t, "APIVersionDeprecated/v1/Foo", ruleResult.Rule.ID)
 	require.Equal(t, uint(10), ruleResult.Rule.Severity)
@@ -44,3 +48,103 @@ func TestDeprecatedApiVersionBasic(t *testing.T) {
 	require.Equal(t, "bar", ruleResult.Violations[0].Reference.Namespace)
 	require.Equal(t, "baz", ruleResult.Violations[0].Reference.Name)
 }
+
+func TestDeprecatedApiVersionTargetVersion(t *testing.T) {
+	node := &graph.Node{
+		Reference: graph.ObjectReference{
+			ApiVersion: "policy/v1beta1",
+			Kind:       "PodDisruptionBudget",
+			Namespace:  "bar",
+			Name:       "baz",
+		},
+	}
+	deprecations := map[string]Deprecation{
+		"policy/v1beta1/PodDisruptionBudget": {
+			Component:     "kubernetes",
+			ApiVersion:    "policy/v1beta1",
+			Kind:          "PodDisruptionBudget",
+			DeprecatedIn:  "v1.21.0",
+			RemovedIn:     "v1.25.0",
+			NewApiVersion: "policy/v1",
+		},
+	}
+	tests := []struct {
+		target   string
+		id       string
+		severity uint
+		status   DeprecationStatus
+	}{
+		{target: "v1.20", id: "APIVersionPendingDeprecation/policy/v1beta1/PodDisruptionBudget", severity: 1, status: DeprecationStatusPending},
+		{target: "v1.23.5", id: "APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", severity: 5, status: DeprecationStatusDeprecated},
+		{target: "v1.25", id: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", severity: 10, status: DeprecationStatusRemoved},
+		{target: "v1.26.1-eks-1", id: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", severity: 10, status: DeprecationStatusRemoved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			ruleResult := deprecatedApiVersion(deprecations, version.MustParseGeneric(tt.target), node)
+			require.NotNil(t, ruleResult)
+			require.Equal(t, tt.id, ruleResult.Rule.ID)
+			require.Equal(t, tt.severity, ruleResult.Rule.Severity)
+			require.Equal(t, tt.status, ruleResult.Rule.DeprecationStatus)
+		})
+	}
+}
+
+func TestLoadDeprecationsFiles(t *testing.T) {
+	dir := t.TempDir()
+	fs := fstest.MapFS{
+		"deprecated-versions.yaml": &fstest.MapFile{Data: []byte(`
+- component: kubernetes
+  apiVersion: policy/v1beta1
+  kind: PodDisruptionBudget
+  newApiVersion: policy/v1
+  deprecatedIn: v1.21
+  removedIn: v1.25
+  link: https://kubernetes.io
+`)},
+	}
+	override := filepath.Join(dir, "override.yaml")
+	err := os.WriteFile(override, []byte(`
+- component: kubernetes
+  apiVersion: policy/v1beta1
+  kind: PodDisruptionBudget
+  newApiVersion: policy/v1
+  deprecatedIn: v1.21
+  removedIn: v1.26
+  link: https://example.com
+- component: example-operator
+  apiVersion: example.com/v1alpha1
+  kind: Widget
+  newApiVersion: example.com/v1
+  deprecatedIn: v1.0
+  link: https://example.com
+`), 0o600)
+	require.NoError(t, err)
+
+	deprecations, embeddedVersion, err := loadDeprecations(fs, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, deprecations, 1)
+	deprecations, mergedVersion, err := loadDeprecations(fs, []string{override}, nil)
+	require.NoError(t, err)
+	require.Len(t, deprecations, 2)
+	require.NotEqual(t, embeddedVersion, mergedVersion)
+	require.Equal(t, "v1.26", deprecations["policy/v1beta1/PodDisruptionBudget"].RemovedIn)
+
+	deprecations, _, err = loadDeprecations(fs, []string{override}, []string{"example-operator"})
+	require.NoError(t, err)
+	require.Len(t, deprecations, 1)
+	require.Contains(t, deprecations, "example.com/v1alpha1/Widget")
+	_, _, err = loadDeprecations(fs, []string{override}, []string{"missing"})
+	require.EqualError(t, err, "unknown deprecation component: missing")
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	err = os.WriteFile(invalid, []byte(`
+- component: example-operator
+  apiVersion: example.com/v1alpha1
+  kind: Widget
+  deprecatedIn: v1.0
+`), 0o600)
+	require.NoError(t, err)
+	_, _, err = loadDeprecations(fs, []string{invalid}, nil)
+	require.EqualError(t, err, invalid+": entry 0 (example.com/v1alpha1/Widget): link cannot be empty")
+}
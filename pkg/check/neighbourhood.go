@@ -0,0 +1,43 @@
+package check
+
+import (
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// neighbourhood is an object together with its neighbours in the graph, which is the input of custom rules.
+type neighbourhood struct {
+	Object map[string]interface{} `json:"object"`
+	// RootOwner is the top most owner of the object, which is the object itself if it has no owner.
+	RootOwner map[string]interface{} `json:"rootOwner"`
+	// Owners are the direct owners of the object.
+	Owners []interface{} `json:"owners"`
+	// Edges are all edges to and from the object. Every edge has a type, a direction which is
+	// either "to" for edges from the object or "from" for edges to the object, and the other object.
+	Edges []interface{} `json:"edges"`
+}
+
+func newNeighbourhood(node *graph.Node, g *graph.Graph) neighbourhood {
+	n := neighbourhood{
+		Object:    node.Unstructured.Object,
+		RootOwner: g.FindRootOwner(node).Unstructured.Object,
+		Owners:    []interface{}{},
+		Edges:     []interface{}{},
+	}
+	for _, edge := range g.Edges(node) {
+		direction := graph.RelationshipDirectionTo
+		other := edge.To().(*graph.Node)
+		if edge.To().ID() == node.ID() {
+			direction = graph.RelationshipDirectionFrom
+			other = edge.From().(*graph.Node)
+		}
+		if edge.Type == graph.EdgeTypeOwner && direction == graph.RelationshipDirectionFrom {
+			n.Owners = append(n.Owners, other.Unstructured.Object)
+		}
+		n.Edges = append(n.Edges, map[string]interface{}{
+			"type":      string(edge.Type),
+			"direction": string(direction),
+			"object":    other.Unstructured.Object,
+		})
+	}
+	return n
+}
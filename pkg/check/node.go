@@ -28,7 +28,7 @@ func nodePremiumStorage(ctx context.Context, node *graph.Node, graph *graph.Grap
 	if !ok {
 		return true, []string{fmt.Sprintf("label %s missing from node", storageTierKey)}, nil
 	}
-	if storageTier != "Premium_LRS" {
+	if storageTier != stringParameter(ctx, "storageTier") {
 		return true, nil, nil
 	}
 	return false, nil, nil
@@ -40,7 +40,7 @@ func nodeBurstableTypes(ctx context.Context, node *graph.Node, graph *graph.Grap
 	if !ok {
 		return true, []string{fmt.Sprintf("label %s missing from node", instanceTypeKey)}, nil
 	}
-	if strings.HasPrefix(instanceType, "Standard_B") {
+	if strings.HasPrefix(instanceType, stringParameter(ctx, "instanceTypePrefix")) {
 		return true, nil, nil
 	}
 	return false, nil, nil
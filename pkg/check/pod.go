@@ -58,7 +58,7 @@ func podReadinessInitialDelayHigh(ctx context.Context, node *graph.Node, graph *
 		if c.ReadinessProbe == nil {
 			continue
 		}
-		if c.ReadinessProbe.InitialDelaySeconds <= 30 {
+		if int(c.ReadinessProbe.InitialDelaySeconds) <= intParameter(ctx, "maxInitialDelaySeconds") {
 			continue
 		}
 		messages = append(messages, fmt.Sprintf("container %s", c.Name))
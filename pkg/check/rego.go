@@ -0,0 +1,224 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+const regoMetadataRule = "__rego_metadata__"
+
+// regoResultRules are the rules in a policy which contain its violations.
+var regoResultRules = []string{"deny", "violation"}
+
+// regoMetadata is the metadata of a policy, read from the __rego_metadata__ rule of its package.
+type regoMetadata struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Severity    uint     `json:"severity"`
+	Link        string   `json:"link"`
+	Kinds       []string `json:"kinds"`
+	Custom      struct {
+		Severity uint `json:"severity"`
+	} `json:"custom"`
+}
+
+// LoadRegoRules reads and compiles the rego modules in the directory, keyed by the kind they are
+// evaluated for. Every package with a deny or violation rule becomes a rule. Partial rules report
+// each value as a violation, complete rules such as deny { ... } report the rule description when true.
+func LoadRegoRules(ctx context.Context, dir string) (map[string][]Rule, error) {
+	modules := map[string]*ast.Module{}
+	err := filepath.WalkDir(dir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		module, err := ast.ParseModule(path, string(b))
+		if err != nil {
+			return err
+		}
+		modules[path] = module
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read rego modules: %w", err)
+	}
+	compiler := ast.NewCompiler()
+	compiler.Compile(modules)
+	if compiler.Failed() {
+		return nil, fmt.Errorf("could not compile rego modules: %w", compiler.Errors)
+	}
+
+	packages := map[string][]string{}
+	for _, module := range modules {
+		pkg := module.Package.Path.String()
+		for _, rule := range module.Rules {
+			name := rule.Head.Name.String()
+			for _, resultRule := range regoResultRules {
+				if name == resultRule && !containsString(packages[pkg], name) {
+					packages[pkg] = append(packages[pkg], name)
+				}
+			}
+		}
+	}
+	pkgs := []string{}
+	for pkg := range packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	rules := map[string][]Rule{}
+	ids := map[string]bool{}
+	for _, pkg := range pkgs {
+		rule, kinds, err := newRegoRule(ctx, compiler, pkg, packages[pkg])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(pkg, "data."), err)
+		}
+		if ids[rule.ID] {
+			return nil, fmt.Errorf("%s: duplicate rule id %s", strings.TrimPrefix(pkg, "data."), rule.ID)
+		}
+		ids[rule.ID] = true
+		if len(kinds) == 0 {
+			kinds = []string{"all"}
+		}
+		for _, kind := range kinds {
+			kind = strings.ToLower(kind)
+			rules[kind] = append(rules[kind], rule)
+		}
+	}
+	return rules, nil
+}
+
+func newRegoRule(ctx context.Context, compiler *ast.Compiler, pkg string, resultRules []string) (Rule, []string, error) {
+	metadata := regoMetadata{}
+	rs, err := rego.New(rego.Query(pkg+"."+regoMetadataRule), rego.Compiler(compiler)).Eval(ctx)
+	if err != nil {
+		return Rule{}, nil, fmt.Errorf("could not evaluate metadata: %w", err)
+	}
+	if len(rs) > 0 && len(rs[0].Expressions) > 0 {
+		b, err := json.Marshal(rs[0].Expressions[0].Value)
+		if err != nil {
+			return Rule{}, nil, err
+		}
+		if err := json.Unmarshal(b, &metadata); err != nil {
+			return Rule{}, nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+	}
+
+	rule := Rule{
+		ID:          metadata.ID,
+		Severity:    metadata.Severity,
+		Description: metadata.Description,
+		Link:        metadata.Link,
+	}
+	if rule.ID == "" {
+		rule.ID = strings.TrimPrefix(pkg, "data.")
+	}
+	if rule.Severity == 0 {
+		rule.Severity = metadata.Custom.Severity
+	}
+	if rule.Severity == 0 {
+		rule.Severity = 5
+	}
+	if rule.Severity > 10 {
+		return Rule{}, nil, fmt.Errorf("severity cannot be greater than 10")
+	}
+	if rule.Description == "" {
+		rule.Description = metadata.Title
+	}
+	if rule.Description == "" {
+		rule.Description = fmt.Sprintf("Policy %s is violated.", rule.ID)
+	}
+
+	queries := []rego.PreparedEvalQuery{}
+	for _, resultRule := range resultRules {
+		query, err := rego.New(rego.Query(pkg+"."+resultRule), rego.Compiler(compiler)).PrepareForEval(ctx)
+		if err != nil {
+			return Rule{}, nil, fmt.Errorf("could not prepare query for %s: %w", resultRule, err)
+		}
+		queries = append(queries, query)
+	}
+	rule.Evaluate = regoEvaluate(queries, rule.Description)
+	return rule, metadata.Kinds, nil
+}
+
+// regoEvaluate returns an evaluate function for the queries. The input contains the object, also as
+// review.object for compatibility with Gatekeeper policies, and its neighbourhood in the graph.
+// The description is used as message when a complete rule evaluates to true.
+func regoEvaluate(queries []rego.PreparedEvalQuery, description string) EvaluateFunction {
+	return func(ctx context.Context, node *graph.Node, g *graph.Graph) (bool, []string, error) {
+		n := newNeighbourhood(node, g)
+		input := map[string]interface{}{
+			"object": n.Object,
+			"review": map[string]interface{}{
+				"object": n.Object,
+			},
+			"rootOwner": n.RootOwner,
+			"owners":    n.Owners,
+			"edges":     n.Edges,
+		}
+		messages := []string{}
+		for _, query := range queries {
+			rs, err := query.Eval(ctx, rego.EvalInput(input))
+			if err != nil {
+				return false, nil, fmt.Errorf("could not evaluate policy for %s: %w", node.Reference.ID(), err)
+			}
+			for _, result := range rs {
+				for _, expression := range result.Expressions {
+					switch value := expression.Value.(type) {
+					case bool:
+						if value {
+							messages = append(messages, description)
+						}
+					case []interface{}:
+						for _, v := range value {
+							messages = append(messages, regoMessage(v))
+						}
+					}
+				}
+			}
+		}
+		sort.Strings(messages)
+		return len(messages) > 0, messages, nil
+	}
+}
+
+// regoMessage returns the message of a violation, which is either a string or an object with a msg field.
+func regoMessage(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if msg, ok := v["msg"].(string); ok {
+			return msg
+		}
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
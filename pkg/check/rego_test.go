@@ -0,0 +1,104 @@
+package check
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestLoadRegoRules(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "latest.rego"), []byte(`
+package kubernetes.latest
+
+__rego_metadata__ := {
+	"id": "ImageLatestTag",
+	"title": "Images should not use the latest tag.",
+	"custom": {"severity": 6},
+	"kinds": ["Pod"],
+}
+
+violation[{"msg": msg}] {
+	container := input.review.object.spec.containers[_]
+	not contains(container.image, ":")
+	msg := sprintf("container %v uses the latest tag", [container.name])
+}
+`), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, "unused.rego"), []byte(`
+package kubernetes.unused
+
+deny[msg] {
+	count(input.edges) == 0
+	msg := "object is not used"
+}
+`), 0o600)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "default.rego"), []byte(`
+package kubernetes.namespace
+
+__rego_metadata__ := {
+	"id": "DefaultNamespace",
+	"title": "Pods should not run in the default namespace.",
+	"kinds": ["Pod"],
+}
+
+deny {
+	input.object.metadata.namespace == "default"
+}
+`), 0o600)
+	require.NoError(t, err)
+
+	rules, err := LoadRegoRules(context.Background(), dir)
+	require.NoError(t, err)
+	require.Len(t, rules["pod"], 2)
+	sort.Slice(rules["pod"], func(i, j int) bool {
+		return rules["pod"][i].ID < rules["pod"][j].ID
+	})
+	require.Equal(t, "DefaultNamespace", rules["pod"][0].ID)
+	require.Equal(t, "ImageLatestTag", rules["pod"][1].ID)
+	require.Equal(t, uint(6), rules["pod"][1].Severity)
+	require.Len(t, rules["all"], 1)
+	require.Equal(t, "kubernetes.unused", rules["all"][0].ID)
+
+	g := graph.NewGraph()
+	err = g.AddUnstructuredNode(unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "foo", "namespace": "default", "uid": "1"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "nginx"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy:v1"},
+			},
+		},
+	}})
+	require.NoError(t, err)
+	node := g.NodeByReferenceID("v1/Pod/default/foo")
+
+	violated, messages, err := rules["pod"][0].Evaluate(context.Background(), node, g)
+	require.NoError(t, err)
+	require.True(t, violated)
+	require.Equal(t, []string{"Pods should not run in the default namespace."}, messages)
+	violated, messages, err = rules["pod"][1].Evaluate(context.Background(), node, g)
+	require.NoError(t, err)
+	require.True(t, violated)
+	require.Equal(t, []string{"container app uses the latest tag"}, messages)
+	violated, messages, err = rules["all"][0].Evaluate(context.Background(), node, g)
+	require.NoError(t, err)
+	require.True(t, violated)
+	require.Equal(t, []string{"object is not used"}, messages)
+
+	node.Unstructured.SetNamespace("foo")
+	violated, messages, err = rules["pod"][0].Evaluate(context.Background(), node, g)
+	require.NoError(t, err)
+	require.False(t, violated)
+	require.Empty(t, messages)
+}
@@ -5,6 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/xenitab/kube-checker/pkg/graph"
 )
@@ -17,6 +21,18 @@ type Rule struct {
 	Description string
 	Link        string
 	Evaluate    EvaluateFunction
+	// Disabled rules are not evaluated unless they are enabled in the config.
+	Disabled bool
+	// Parameters are the default values of the parameters which can be set in the config.
+	Parameters map[string]interface{}
+	// Namespaces limits the rule to objects in the namespaces, all namespaces if empty.
+	Namespaces []string
+	// Selector limits the rule to objects with matching labels, all objects if nil.
+	Selector labels.Selector
+	// Deprecation is set for rules of deprecated api versions.
+	Deprecation *Deprecation
+	// DeprecationStatus is the status of the deprecation in the target version, empty if there is no target version.
+	DeprecationStatus DeprecationStatus
 }
 
 func (r *Rule) Validate() error {
@@ -39,16 +55,42 @@ func (r *Rule) Validate() error {
 }
 
 type Violation struct {
+	// Reference is the root owner of the object which violated the rule.
 	Reference graph.ObjectReference
-	Message   string
+	// Object is the object which violated the rule.
+	Object graph.ObjectReference
+	// Source is the file the object was read from, empty if the object was read from a cluster.
+	Source  graph.Source
+	Message string
+	// Suppression is set if the violation is suppressed by an ignore annotation.
+	Suppression *Suppression
+}
+
+// Evaluation is the outcome of evaluating a rule for a single object.
+type Evaluation struct {
+	Object graph.ObjectReference
+	// RootOwner is the reference the violation is reported against, see Violation.Reference.
+	RootOwner graph.ObjectReference
+	Violated  bool
+	// Suppressed is true if the violation is suppressed by an ignore annotation.
+	Suppressed bool
+	Message    string
 }
 
 type RuleResult struct {
 	Rule       Rule
 	Violations []Violation
+	// Suppressed contains the violations suppressed by ignore annotations.
+	Suppressed []Violation
+	// Evaluations contains every object the rule has been evaluated for.
+	Evaluations []Evaluation
 }
 
 func (r *RuleResult) AddViolation(violation Violation) {
+	if violation.Suppression != nil {
+		r.addSuppressed(violation)
+		return
+	}
 	for _, v := range r.Violations {
 		if v.Reference.ID() == violation.Reference.ID() {
 			return
@@ -57,6 +99,45 @@ func (r *RuleResult) AddViolation(violation Violation) {
 	r.Violations = append(r.Violations, violation)
 }
 
+// merge adds the violations and evaluations of the other result.
+func (r *RuleResult) merge(other *RuleResult) {
+	// Rules of deprecated api versions without violations only have a generic description
+	if len(other.Violations) > 0 && len(r.Violations) == 0 && len(r.Suppressed) == 0 {
+		r.Rule = other.Rule
+	}
+	for _, violation := range other.Violations {
+		// Deprecation violations of objects in the same Helm release share the reference
+		if r.Rule.Deprecation != nil {
+			r.Violations = append(r.Violations, violation)
+			continue
+		}
+		r.AddViolation(violation)
+	}
+	for _, violation := range other.Suppressed {
+		if r.Rule.Deprecation != nil {
+			r.Suppressed = append(r.Suppressed, violation)
+			continue
+		}
+		r.addSuppressed(violation)
+	}
+	r.Evaluations = append(r.Evaluations, other.Evaluations...)
+}
+
+func (r *RuleResult) addSuppressed(violation Violation) {
+	for _, v := range r.Suppressed {
+		if v.Reference.ID() == violation.Reference.ID() {
+			return
+		}
+	}
+	r.Suppressed = append(r.Suppressed, violation)
+}
+
+// MatchRuleID returns true if the rule ID matches the pattern. Patterns use the same syntax
+// as shell file name globs, except that * also matches the slashes in deprecation rule IDs.
+func MatchRuleID(pattern, id string) (bool, error) {
+	return path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(id, "/", "\x00"))
+}
+
 func getRules() map[string][]Rule {
 	return map[string][]Rule{
 		"all": {
@@ -90,19 +171,26 @@ func getRules() map[string][]Rule {
 			},
 		},
 		"node": {
-			/*{
+			{
 				ID:          "PremiumStorage",
 				Severity:    5,
 				Description: "Node should use premium storage.",
 				Link:        "",
 				Evaluate:    nodePremiumStorage,
-			},*/
+				Disabled:    true,
+				Parameters: map[string]interface{}{
+					"storageTier": "Premium_LRS",
+				},
+			},
 			{
 				ID:          "BurstableInstanceType",
 				Severity:    5,
 				Description: "Node should not use burstable types.",
 				Link:        "",
 				Evaluate:    nodeBurstableTypes,
+				Parameters: map[string]interface{}{
+					"instanceTypePrefix": "Standard_B",
+				},
 			},
 			{
 				ID:          "XKSLabel",
@@ -147,13 +235,16 @@ func getRules() map[string][]Rule {
 				Description: "Readiness probe initial delay is high, did you mean to use startup probe?",
 				Link:        "",
 				Evaluate:    podReadinessInitialDelayHigh,
+				Parameters: map[string]interface{}{
+					"maxInitialDelaySeconds": 30,
+				},
 			},
 			{
 				ID:          "MissingReadinessProbe",
 				Severity:    5,
 				Description: "Pod missing readiness probe.",
 				Link:        "",
-				Evaluate:    podReadinessInitialDelayHigh,
+				Evaluate:    podMissingReadinessProbe,
 			},
 			{
 				ID:          "ReadinessAndLivenessSame",
@@ -0,0 +1,57 @@
+package check
+
+import (
+	"strings"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+const (
+	// IgnoreAnnotation contains a comma separated list of rule IDs or patterns which should not be reported for the object.
+	IgnoreAnnotation = "kube-checker.xenit.io/ignore"
+	// IgnoreReasonAnnotation explains why the rules in the ignore annotation are ignored.
+	IgnoreReasonAnnotation = "kube-checker.xenit.io/ignore-reason"
+)
+
+// Suppression describes why a violation is not reported.
+type Suppression struct {
+	// Object is the object with the ignore annotation, either the violating object or its root owner.
+	Object graph.ObjectReference
+	Reason string
+}
+
+// WithRequireIgnoreReason only suppresses violations if the ignore reason annotation is set.
+func WithRequireIgnoreReason() Option {
+	return func(c *Checker) error {
+		c.requireIgnoreReason = true
+		return nil
+	}
+}
+
+// suppression returns the suppression of the rule by the first of the nodes with a
+// matching ignore annotation, nil if the rule is not suppressed.
+func (c *Checker) suppression(ruleID string, nodes ...*graph.Node) *Suppression {
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		annotations := node.Unstructured.GetAnnotations()
+		reason := annotations[IgnoreReasonAnnotation]
+		if c.requireIgnoreReason && reason == "" {
+			continue
+		}
+		for _, pattern := range strings.Split(annotations[IgnoreAnnotation], ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if ok, _ := MatchRuleID(pattern, ruleID); ok {
+				return &Suppression{
+					Object: node.Reference,
+					Reason: reason,
+				}
+			}
+		}
+	}
+	return nil
+}
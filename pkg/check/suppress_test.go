@@ -0,0 +1,38 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSuppression(t *testing.T) {
+	newNode := func(name string, annotations map[string]string) *graph.Node {
+		u := unstructured.Unstructured{}
+		u.SetAnnotations(annotations)
+		return &graph.Node{
+			Unstructured: u,
+			Reference:    graph.ObjectReference{ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: name},
+		}
+	}
+	node := newNode("pod", map[string]string{
+		IgnoreAnnotation: "NoTLS",
+	})
+	rootNode := newNode("root", map[string]string{
+		IgnoreAnnotation:       "UnusedResource, APIVersion*",
+		IgnoreReasonAnnotation: "kept for debugging",
+	})
+
+	c := &Checker{}
+	require.Equal(t, &Suppression{Object: node.Reference}, c.suppression("NoTLS", node, rootNode))
+	require.Equal(t, &Suppression{Object: rootNode.Reference, Reason: "kept for debugging"}, c.suppression("UnusedResource", node, rootNode))
+	require.NotNil(t, c.suppression("APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", node, rootNode))
+	require.Nil(t, c.suppression("WithoutController", node, rootNode))
+	require.Nil(t, c.suppression("NoTLS", nil))
+
+	c = &Checker{requireIgnoreReason: true}
+	require.Nil(t, c.suppression("NoTLS", node, rootNode))
+	require.NotNil(t, c.suppression("UnusedResource", node, rootNode))
+}
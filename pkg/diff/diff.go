@@ -0,0 +1,333 @@
+// Package diff compares the graphs and reports of two scans.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+)
+
+// Scan is the graph and report of a single scan.
+type Scan struct {
+	Graph  *graph.Graph
+	Report report.Report
+}
+
+// Diff is the difference between two scans.
+type Diff struct {
+	From    report.Metadata `json:"from"`
+	To      report.Metadata `json:"to"`
+	Objects ObjectChanges   `json:"objects"`
+	Edges   EdgeChanges     `json:"edges"`
+	// Rules are the rules with new or resolved violations, sorted by severity and rule ID.
+	Rules []RuleChanges `json:"rules"`
+}
+
+// ObjectChanges are the objects which were added, removed or changed. Changes to the resource
+// version, generation, managed fields and status of an object are ignored.
+type ObjectChanges struct {
+	Added   []graph.ObjectReference `json:"added"`
+	Removed []graph.ObjectReference `json:"removed"`
+	Changed []graph.ObjectReference `json:"changed"`
+}
+
+// EdgeChanges are the edges which were added or removed.
+type EdgeChanges struct {
+	Added   []Edge `json:"added"`
+	Removed []Edge `json:"removed"`
+}
+
+// Edge is an edge between two objects.
+type Edge struct {
+	Type graph.EdgeType        `json:"type"`
+	From graph.ObjectReference `json:"from"`
+	To   graph.ObjectReference `json:"to"`
+}
+
+func (e Edge) id() string {
+	return fmt.Sprintf("%s\x00%s\x00%s", e.From.ID(), e.Type, e.To.ID())
+}
+
+// key identifies the edge by the keys of its objects.
+func (e Edge) key() string {
+	return fmt.Sprintf("%s\x00%s\x00%s", objectKey(e.From), e.Type, objectKey(e.To))
+}
+
+// RuleChanges are the new and resolved violations of a rule.
+type RuleChanges struct {
+	Rule     report.Rule        `json:"rule"`
+	New      []report.Violation `json:"new"`
+	Resolved []report.Violation `json:"resolved"`
+}
+
+// New compares the scans. Objects are matched by group, kind, namespace and name, so an object with
+// a changed api version is reported as changed. Violations are matched by rule, root owner and message.
+func New(from, to Scan) Diff {
+	return Diff{
+		From:    from.Report.Metadata,
+		To:      to.Report.Metadata,
+		Objects: diffObjects(from.Graph, to.Graph),
+		Edges:   diffEdges(from.Graph, to.Graph),
+		Rules:   diffViolations(from.Report, to.Report),
+	}
+}
+
+// Failing returns the IDs of the rules with new violations that either have a severity equal
+// to or above the threshold or match any of the rule ID patterns, like report.Report.Failing.
+func (d Diff) Failing(threshold uint, rulePatterns []string) []string {
+	r := report.Report{}
+	for _, rule := range d.Rules {
+		r.Results = append(r.Results, report.Result{Rule: rule.Rule, Violations: rule.New})
+	}
+	return r.Failing(threshold, rulePatterns)
+}
+
+func diffObjects(from, to *graph.Graph) ObjectChanges {
+	fromObjects := objectsByKey(from)
+	toObjects := objectsByKey(to)
+	changes := ObjectChanges{
+		Added:   []graph.ObjectReference{},
+		Removed: []graph.ObjectReference{},
+		Changed: []graph.ObjectReference{},
+	}
+	for key, u := range toObjects {
+		fromU, ok := fromObjects[key]
+		if !ok {
+			changes.Added = append(changes.Added, graph.ReferenceForObject(u))
+			continue
+		}
+		if !reflect.DeepEqual(comparableObject(fromU), comparableObject(u)) {
+			changes.Changed = append(changes.Changed, graph.ReferenceForObject(u))
+		}
+	}
+	for key, u := range fromObjects {
+		if _, ok := toObjects[key]; !ok {
+			changes.Removed = append(changes.Removed, graph.ReferenceForObject(u))
+		}
+	}
+	for _, references := range [][]graph.ObjectReference{changes.Added, changes.Removed, changes.Changed} {
+		sortReferences(references)
+	}
+	return changes
+}
+
+func objectsByKey(g *graph.Graph) map[string]unstructured.Unstructured {
+	objects := map[string]unstructured.Unstructured{}
+	for _, u := range g.Objects() {
+		objects[objectKey(graph.ReferenceForObject(u))] = u
+	}
+	return objects
+}
+
+// objectKey identifies an object across api versions.
+func objectKey(reference graph.ObjectReference) string {
+	gv, _ := schema.ParseGroupVersion(reference.ApiVersion)
+	return fmt.Sprintf("%s/%s/%s/%s", gv.Group, reference.Kind, reference.Namespace, reference.Name)
+}
+
+// comparableObject returns the object without the fields which change without a change to the object itself.
+func comparableObject(u unstructured.Unstructured) map[string]interface{} {
+	u = *u.DeepCopy()
+	unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(u.Object, "metadata", "generation")
+	unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u.Object
+}
+
+func diffEdges(from, to *graph.Graph) EdgeChanges {
+	fromEdges := edgesByKey(from)
+	toEdges := edgesByKey(to)
+	changes := EdgeChanges{
+		Added:   []Edge{},
+		Removed: []Edge{},
+	}
+	for key, edge := range toEdges {
+		if _, ok := fromEdges[key]; !ok {
+			changes.Added = append(changes.Added, edge)
+		}
+	}
+	for key, edge := range fromEdges {
+		if _, ok := toEdges[key]; !ok {
+			changes.Removed = append(changes.Removed, edge)
+		}
+	}
+	for _, edges := range [][]Edge{changes.Added, changes.Removed} {
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].id() < edges[j].id()
+		})
+	}
+	return changes
+}
+
+func edgesByKey(g *graph.Graph) map[string]Edge {
+	edges := map[string]Edge{}
+	g.Iterate(func(node *graph.Node) error {
+		for _, e := range g.Edges(node) {
+			// Every edge is returned for both of its nodes, only the outgoing edges are kept
+			if e.From().ID() != node.ID() {
+				continue
+			}
+			edge := Edge{
+				Type: e.Type,
+				From: node.Reference,
+				To:   e.To().(*graph.Node).Reference,
+			}
+			edges[edge.key()] = edge
+		}
+		return nil
+	})
+	return edges
+}
+
+func diffViolations(from, to report.Report) []RuleChanges {
+	fromViolations := violationsByKey(from)
+	toViolations := violationsByKey(to)
+	changes := map[string]*RuleChanges{}
+	ruleChanges := func(rule report.Rule) *RuleChanges {
+		if _, ok := changes[rule.ID]; !ok {
+			changes[rule.ID] = &RuleChanges{Rule: rule, New: []report.Violation{}, Resolved: []report.Violation{}}
+		}
+		return changes[rule.ID]
+	}
+	for key, v := range toViolations {
+		if _, ok := fromViolations[key]; !ok {
+			rc := ruleChanges(v.rule)
+			rc.New = append(rc.New, v.violation)
+		}
+	}
+	for key, v := range fromViolations {
+		if _, ok := toViolations[key]; !ok {
+			rc := ruleChanges(v.rule)
+			rc.Resolved = append(rc.Resolved, v.violation)
+		}
+	}
+	rules := []RuleChanges{}
+	for _, rc := range changes {
+		for _, violations := range [][]report.Violation{rc.New, rc.Resolved} {
+			sort.Slice(violations, func(i, j int) bool {
+				if violations[i].Object.ID() != violations[j].Object.ID() {
+					return violations[i].Object.ID() < violations[j].Object.ID()
+				}
+				return violations[i].Message < violations[j].Message
+			})
+		}
+		rules = append(rules, *rc)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Rule.Severity != rules[j].Rule.Severity {
+			return rules[i].Rule.Severity > rules[j].Rule.Severity
+		}
+		return rules[i].Rule.ID < rules[j].Rule.ID
+	})
+	return rules
+}
+
+type ruleViolation struct {
+	rule      report.Rule
+	violation report.Violation
+}
+
+// violationsByKey returns the violations keyed by rule, root owner and message, as the objects owned
+// by the root owner, like pods, are renamed when they are replaced.
+func violationsByKey(r report.Report) map[string]ruleViolation {
+	violations := map[string]ruleViolation{}
+	for _, result := range r.Results {
+		for _, v := range result.Violations {
+			key := fmt.Sprintf("%s\x00%s\x00%s", result.Rule.ID, objectKey(v.RootOwner), v.Message)
+			violations[key] = ruleViolation{rule: result.Rule, violation: v}
+		}
+	}
+	return violations
+}
+
+func sortReferences(references []graph.ObjectReference) {
+	sort.Slice(references, func(i, j int) bool {
+		return references[i].ID() < references[j].ID()
+	})
+}
+
+// Write writes the diff in the given format, only table and json are supported.
+func Write(w io.Writer, format report.Format, d Diff) error {
+	switch format {
+	case report.FormatTable:
+		return WriteTable(w, d)
+	case report.FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	default:
+		return fmt.Errorf("output format %s is not supported for diffs", format)
+	}
+}
+
+// WriteTable writes a table of the changed objects, the changed edges and the changed violations.
+func WriteTable(w io.Writer, d Diff) error {
+	objectTable := tablewriter.NewWriter(w)
+	objectTable.SetHeader([]string{"Change", "Api Version", "Kind", "Namespace", "Name"})
+	for _, c := range []struct {
+		change     string
+		references []graph.ObjectReference
+	}{
+		{change: "added", references: d.Objects.Added},
+		{change: "removed", references: d.Objects.Removed},
+		{change: "changed", references: d.Objects.Changed},
+	} {
+		for _, reference := range c.references {
+			objectTable.Append([]string{c.change, reference.ApiVersion, reference.Kind, reference.Namespace, reference.Name})
+		}
+	}
+	objectTable.Render()
+
+	fmt.Fprintf(w, "\n\n\n\n")
+	edgeTable := tablewriter.NewWriter(w)
+	edgeTable.SetHeader([]string{"Change", "Type", "From", "To"})
+	for _, c := range []struct {
+		change string
+		edges  []Edge
+	}{
+		{change: "added", edges: d.Edges.Added},
+		{change: "removed", edges: d.Edges.Removed},
+	} {
+		for _, edge := range c.edges {
+			edgeTable.Append([]string{c.change, string(edge.Type), edge.From.ID(), edge.To.ID()})
+		}
+	}
+	edgeTable.Render()
+
+	fmt.Fprintf(w, "\n\n\n\n")
+	violationTable := tablewriter.NewWriter(w)
+	violationTable.SetHeader([]string{"Change", "ID", "Severity", "Object", "Message"})
+	for _, rule := range d.Rules {
+		severity := strconv.FormatUint(uint64(rule.Rule.Severity), 10)
+		for _, v := range rule.New {
+			violationTable.Append([]string{"new", rule.Rule.ID, severity, v.Object.ID(), v.Message})
+		}
+		for _, v := range rule.Resolved {
+			violationTable.Append([]string{"resolved", rule.Rule.ID, severity, v.Object.ID(), v.Message})
+		}
+	}
+	violationTable.Render()
+	return nil
+}
+
+// String returns a single line with the number of changes.
+func (d Diff) String() string {
+	newViolations, resolved := 0, 0
+	for _, rule := range d.Rules {
+		newViolations += len(rule.New)
+		resolved += len(rule.Resolved)
+	}
+	return fmt.Sprintf("objects: %d added, %d removed, %d changed; edges: %d added, %d removed; violations: %d new, %d resolved",
+		len(d.Objects.Added), len(d.Objects.Removed), len(d.Objects.Changed), len(d.Edges.Added), len(d.Edges.Removed), newViolations, resolved)
+}
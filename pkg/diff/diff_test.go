@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/report"
+	"github.com/xenitab/kube-checker/pkg/snapshot"
+)
+
+func newObject(kind, name string, fields map[string]interface{}) unstructured.Unstructured {
+	u := unstructured.Unstructured{Object: fields}
+	u.SetAPIVersion("v1")
+	u.SetKind(kind)
+	u.SetNamespace("foo")
+	u.SetName(name)
+	u.SetUID(types.UID(kind + "/" + name))
+	return u
+}
+
+func TestNew(t *testing.T) {
+	pod := newObject("Pod", "web", map[string]interface{}{"spec": map[string]interface{}{"serviceAccountName": "web"}})
+	serviceAccount := newObject("ServiceAccount", "web", map[string]interface{}{})
+	configMap := newObject("ConfigMap", "config", map[string]interface{}{})
+	fromGraph := graph.NewGraph()
+	require.NoError(t, fromGraph.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{pod, serviceAccount, configMap}))
+
+	changedPod := *pod.DeepCopy()
+	require.NoError(t, unstructured.SetNestedSlice(changedPod.Object, []interface{}{
+		map[string]interface{}{"name": "credentials", "secret": map[string]interface{}{"secretName": "credentials"}},
+	}, "spec", "volumes"))
+	// Status and resource version changes are ignored
+	unchangedServiceAccount := *serviceAccount.DeepCopy()
+	unchangedServiceAccount.SetResourceVersion("2")
+	unchangedServiceAccount.Object["status"] = map[string]interface{}{"phase": "Active"}
+	secret := newObject("Secret", "credentials", map[string]interface{}{})
+	toGraph := graph.NewGraph()
+	require.NoError(t, toGraph.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{changedPod, unchangedServiceAccount, secret}))
+
+	podReference := graph.ReferenceForObject(pod)
+	serviceAccountReference := graph.ReferenceForObject(serviceAccount)
+	withoutController := check.Rule{ID: "WithoutController", Severity: 8}
+	noTLS := check.Rule{ID: "NoTLS", Severity: 6}
+	fromReport := report.New(report.Metadata{}, nil, map[string]*check.RuleResult{
+		withoutController.ID: {Rule: withoutController, Violations: []check.Violation{{Reference: podReference, Object: podReference}}},
+		noTLS.ID:             {Rule: noTLS, Violations: []check.Violation{{Reference: serviceAccountReference, Object: serviceAccountReference}}},
+	})
+	toReport := report.New(report.Metadata{}, nil, map[string]*check.RuleResult{
+		withoutController.ID: {Rule: withoutController, Violations: []check.Violation{{Reference: podReference, Object: podReference, Message: "changed"}}},
+	})
+
+	d := New(Scan{Graph: fromGraph, Report: fromReport}, Scan{Graph: toGraph, Report: toReport})
+	require.Equal(t, []graph.ObjectReference{graph.ReferenceForObject(secret)}, d.Objects.Added)
+	require.Equal(t, []graph.ObjectReference{graph.ReferenceForObject(configMap)}, d.Objects.Removed)
+	require.Equal(t, []graph.ObjectReference{podReference}, d.Objects.Changed)
+	require.Equal(t, []Edge{{Type: graph.EdgeTypeConsumes, From: podReference, To: graph.ReferenceForObject(secret)}}, d.Edges.Added)
+	require.Empty(t, d.Edges.Removed)
+
+	require.Len(t, d.Rules, 2)
+	require.Equal(t, withoutController.ID, d.Rules[0].Rule.ID)
+	require.Len(t, d.Rules[0].New, 1)
+	require.Len(t, d.Rules[0].Resolved, 1)
+	require.Equal(t, noTLS.ID, d.Rules[1].Rule.ID)
+	require.Empty(t, d.Rules[1].New)
+	require.Len(t, d.Rules[1].Resolved, 1)
+	require.Equal(t, []string{withoutController.ID}, d.Failing(7, nil))
+	require.Empty(t, d.Failing(0, []string{"NoTLS"}))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, report.FormatTable, d))
+	require.Contains(t, buf.String(), "v1/Secret/foo/credentials")
+	require.Error(t, Write(buf, report.FormatSARIF, d))
+}
+
+func TestNewMatchesAcrossVersionsAndReplacedPods(t *testing.T) {
+	pdb := newObject("PodDisruptionBudget", "web", map[string]interface{}{})
+	pdb.SetAPIVersion("policy/v1beta1")
+	fromGraph := graph.NewGraph()
+	require.NoError(t, fromGraph.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{pdb}))
+	upgradedPDB := *pdb.DeepCopy()
+	upgradedPDB.SetAPIVersion("policy/v1")
+	toGraph := graph.NewGraph()
+	require.NoError(t, toGraph.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{upgradedPDB}))
+
+	// Pods are renamed when replaced, violations are matched by their root owner
+	replicaSet := graph.ObjectReference{ApiVersion: "apps/v1", Kind: "ReplicaSet", Namespace: "foo", Name: "web"}
+	oldPod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web-abc"}
+	newPod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web-def"}
+	probe := check.Rule{ID: "MissingReadinessProbe", Severity: 5}
+	fromReport := report.New(report.Metadata{}, nil, map[string]*check.RuleResult{
+		probe.ID: {Rule: probe, Violations: []check.Violation{{Reference: replicaSet, Object: oldPod, Message: "container web"}}},
+	})
+	toReport := report.New(report.Metadata{}, nil, map[string]*check.RuleResult{
+		probe.ID: {Rule: probe, Violations: []check.Violation{{Reference: replicaSet, Object: newPod, Message: "container web"}}},
+	})
+
+	d := New(Scan{Graph: fromGraph, Report: fromReport}, Scan{Graph: toGraph, Report: toReport})
+	require.Empty(t, d.Objects.Added)
+	require.Empty(t, d.Objects.Removed)
+	require.Equal(t, []graph.ObjectReference{graph.ReferenceForObject(upgradedPDB)}, d.Objects.Changed)
+	require.Empty(t, d.Rules)
+}
+
+func TestNewSnapshotAndCluster(t *testing.T) {
+	// Objects read from the cluster contain integers as int64
+	pod := newObject("Pod", "web", map[string]interface{}{"spec": map[string]interface{}{
+		"terminationGracePeriodSeconds": int64(30),
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name":  "web",
+				"ports": []interface{}{map[string]interface{}{"containerPort": int64(8080)}},
+			},
+		},
+	}})
+	cluster := graph.NewGraph()
+	require.NoError(t, cluster.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{pod}))
+
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	require.NoError(t, snapshot.New(cluster, "foo", false).Write(path))
+	s, err := snapshot.Load(path)
+	require.NoError(t, err)
+	loaded := graph.NewGraph()
+	require.NoError(t, s.Populate(context.Background(), loaded))
+
+	d := New(Scan{Graph: loaded, Report: report.New(report.Metadata{}, nil, nil)}, Scan{Graph: cluster, Report: report.New(report.Metadata{}, nil, nil)})
+	require.Empty(t, d.Objects.Added)
+	require.Empty(t, d.Objects.Removed)
+	require.Empty(t, d.Objects.Changed)
+}
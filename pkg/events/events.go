@@ -0,0 +1,249 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+const (
+	// Component is the source component of the events.
+	Component = "kube-checker"
+
+	// refreshInterval is the interval at which unchanged events are recorded again, which has
+	// to be shorter than the time to live of events in the cluster to keep them visible.
+	refreshInterval = 30 * time.Minute
+	// maxMessageLength is the maximum length of an event message accepted by the api server.
+	maxMessageLength = 1024
+	// maxNameLength is the maximum length of the object name used as prefix of the event name.
+	maxNameLength = 200
+)
+
+// ResolveFunc returns the UID of the object, false if the object is not in the cluster.
+type ResolveFunc func(reference graph.ObjectReference) (types.UID, bool)
+
+// Recorder records Warning events on the objects of violations. Events have a name derived
+// from the object and rule, so the same event is updated instead of created again across runs.
+type Recorder struct {
+	client  kubernetes.Interface
+	limiter *rate.Limiter
+
+	mu       sync.Mutex
+	recorded map[string]recordedEvent
+	now      func() time.Time
+
+	// queueMu guards the results queued to be recorded by Run, only the latest results are kept.
+	queueMu sync.Mutex
+	queued  *queuedResults
+	notify  chan struct{}
+}
+
+type queuedResults struct {
+	ruleResults map[string]*check.RuleResult
+	resolve     ResolveFunc
+}
+
+type recordedEvent struct {
+	message string
+	time    time.Time
+}
+
+// NewRecorder returns a recorder which records at most qps events per second.
+func NewRecorder(client kubernetes.Interface, qps float64) *Recorder {
+	burst := int(qps)
+	if burst < 1 {
+		burst = 1
+	}
+	return &Recorder{
+		client:   client,
+		limiter:  rate.NewLimiter(rate.Limit(qps), burst),
+		recorded: map[string]recordedEvent{},
+		now:      time.Now,
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// Queue queues the results to be recorded by Run without waiting for the rate limit, replacing
+// results which have not been recorded yet.
+func (r *Recorder) Queue(ruleResults map[string]*check.RuleResult, resolve ResolveFunc) {
+	r.queueMu.Lock()
+	r.queued = &queuedResults{ruleResults: ruleResults, resolve: resolve}
+	r.queueMu.Unlock()
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run records the queued results until the context is cancelled.
+func (r *Recorder) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx).WithName("events")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.notify:
+			r.queueMu.Lock()
+			queued := r.queued
+			r.queued = nil
+			r.queueMu.Unlock()
+			if queued == nil {
+				continue
+			}
+			err := r.Record(ctx, queued.ruleResults, queued.resolve)
+			if err != nil && ctx.Err() == nil {
+				logger.Error(err, "could not record events")
+			}
+		}
+	}
+}
+
+// Record records an event for every object in the violations. Objects which cannot be
+// resolved, like the objects in Helm releases, are skipped. Events which are no longer
+// violated are forgotten, so they are recorded again if the violation reappears.
+func (r *Recorder) Record(ctx context.Context, ruleResults map[string]*check.RuleResult, resolve ResolveFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []string{}
+	for id := range ruleResults {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	current := map[string]bool{}
+	for _, id := range ids {
+		ruleResult := ruleResults[id]
+		for _, violation := range ruleResult.Violations {
+			references := []graph.ObjectReference{violation.Object}
+			if violation.Reference != violation.Object {
+				references = append(references, violation.Reference)
+			}
+			for _, reference := range references {
+				uid, ok := resolve(reference)
+				if !ok {
+					continue
+				}
+				current[eventKey(reference, uid, ruleResult.Rule.ID)] = true
+				err := r.record(ctx, ruleResult.Rule, violation, reference, uid)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for key := range r.recorded {
+		if !current[key] {
+			delete(r.recorded, key)
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) record(ctx context.Context, rule check.Rule, violation check.Violation, reference graph.ObjectReference, uid types.UID) error {
+	namespace := eventNamespace(reference)
+	name := eventName(reference, uid, rule.ID)
+	message := rule.Description
+	if violation.Message != "" {
+		message = fmt.Sprintf("%s %s", message, violation.Message)
+	}
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength]
+	}
+
+	now := r.now()
+	key := eventKey(reference, uid, rule.ID)
+	if recorded, ok := r.recorded[key]; ok && recorded.message == message && now.Sub(recorded.time) < refreshInterval {
+		return nil
+	}
+	err := r.limiter.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	client := r.client.CoreV1().Events(namespace)
+	event, err := client.Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		event = &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				APIVersion: reference.ApiVersion,
+				Kind:       reference.Kind,
+				Namespace:  reference.Namespace,
+				Name:       reference.Name,
+				UID:        uid,
+			},
+			Reason:              rule.ID,
+			Message:             message,
+			Type:                corev1.EventTypeWarning,
+			Source:              corev1.EventSource{Component: Component},
+			ReportingController: Component,
+			FirstTimestamp:      metav1.NewTime(now),
+			LastTimestamp:       metav1.NewTime(now),
+			Count:               1,
+		}
+		_, err := client.Create(ctx, event, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not create event %s/%s: %w", namespace, name, err)
+		}
+		r.recorded[key] = recordedEvent{message: message, time: now}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get event %s/%s: %w", namespace, name, err)
+	}
+	// The event may have been recorded by a previous run
+	if event.Message == message && now.Sub(event.LastTimestamp.Time) < refreshInterval {
+		r.recorded[key] = recordedEvent{message: message, time: event.LastTimestamp.Time}
+		return nil
+	}
+	event.Message = message
+	event.LastTimestamp = metav1.NewTime(now)
+	event.Count++
+	_, err = client.Update(ctx, event, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not update event %s/%s: %w", namespace, name, err)
+	}
+	r.recorded[key] = recordedEvent{message: message, time: now}
+	return nil
+}
+
+// eventNamespace returns the namespace of the event, events of cluster wide objects are stored in the default namespace.
+func eventNamespace(reference graph.ObjectReference) string {
+	if reference.Namespace == "" {
+		return metav1.NamespaceDefault
+	}
+	return reference.Namespace
+}
+
+// eventKey returns the key of the recorded event.
+func eventKey(reference graph.ObjectReference, uid types.UID, ruleID string) string {
+	return eventNamespace(reference) + "/" + eventName(reference, uid, ruleID)
+}
+
+// eventName returns a name which is unique for the object and rule.
+func eventName(reference graph.ObjectReference, uid types.UID, ruleID string) string {
+	name := reference.Name
+	if len(name) > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength], "-.")
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s", reference.ID(), uid, ruleID)))
+	return fmt.Sprintf("%s.%x", name, hash[:8])
+}
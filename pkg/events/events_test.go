@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	recorder := NewRecorder(client, 100)
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time {
+		return now
+	}
+
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web-abc"}
+	deployment := graph.ObjectReference{ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "foo", Name: "web"}
+	release := graph.ObjectReference{ApiVersion: "helm.sh/release.v1", Kind: "HelmRelease", Namespace: "foo", Name: "web"}
+	ruleResults := map[string]*check.RuleResult{
+		"MissingReadinessProbe": {
+			Rule:       check.Rule{ID: "MissingReadinessProbe", Description: "Pods should have a readiness probe."},
+			Violations: []check.Violation{{Reference: deployment, Object: pod, Message: "container web"}},
+		},
+		"APIVersionRemoved/extensions/v1beta1/Ingress": {
+			Rule:       check.Rule{ID: "APIVersionRemoved/extensions/v1beta1/Ingress"},
+			Violations: []check.Violation{{Reference: release, Object: graph.ObjectReference{ApiVersion: "extensions/v1beta1", Kind: "Ingress", Namespace: "foo", Name: "web"}}},
+		},
+	}
+	resolve := func(reference graph.ObjectReference) (types.UID, bool) {
+		switch reference {
+		case pod:
+			return "pod-uid", true
+		case deployment:
+			return "deployment-uid", true
+		}
+		return "", false
+	}
+	require.NoError(t, recorder.Record(ctx, ruleResults, resolve))
+	events, err := client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 2)
+	for _, event := range events.Items {
+		require.Equal(t, "MissingReadinessProbe", event.Reason)
+		require.Equal(t, "Pods should have a readiness probe. container web", event.Message)
+		require.Equal(t, "Warning", event.Type)
+		require.Equal(t, int32(1), event.Count)
+	}
+
+	// Unchanged events are only recorded again after the refresh interval
+	require.NoError(t, recorder.Record(ctx, ruleResults, resolve))
+	events, err = client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), events.Items[0].Count)
+
+	// Unchanged events of previous runs are not updated within the refresh interval
+	recorder = NewRecorder(client, 100)
+	recorder.now = func() time.Time {
+		return now
+	}
+	now = now.Add(time.Minute)
+	require.NoError(t, recorder.Record(ctx, ruleResults, resolve))
+	events, err = client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 2)
+	for _, event := range events.Items {
+		require.Equal(t, int32(1), event.Count)
+	}
+	require.Len(t, recorder.recorded, 2)
+
+	// Events of previous runs are updated instead of created again after the refresh interval
+	recorder = NewRecorder(client, 100)
+	recorder.now = func() time.Time {
+		return now
+	}
+	now = now.Add(refreshInterval)
+	require.NoError(t, recorder.Record(ctx, ruleResults, resolve))
+	events, err = client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, events.Items, 2)
+	for _, event := range events.Items {
+		require.Equal(t, int32(2), event.Count)
+	}
+
+	// Events which are no longer violated are forgotten and recorded again when violated again with another message
+	require.NoError(t, recorder.Record(ctx, map[string]*check.RuleResult{}, resolve))
+	require.Empty(t, recorder.recorded)
+	ruleResults["MissingReadinessProbe"].Violations[0].Message = "container api"
+	require.NoError(t, recorder.Record(ctx, ruleResults, resolve))
+	events, err = client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	for _, event := range events.Items {
+		require.Equal(t, int32(3), event.Count)
+		require.Equal(t, "Pods should have a readiness probe. container api", event.Message)
+	}
+}
+
+func TestRecorderRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := NewRecorder(client, 100)
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web-abc"}
+	resolve := func(reference graph.ObjectReference) (types.UID, bool) {
+		return "pod-uid", true
+	}
+	ruleResults := func(message string) map[string]*check.RuleResult {
+		return map[string]*check.RuleResult{
+			"MissingReadinessProbe": {
+				Rule:       check.Rule{ID: "MissingReadinessProbe", Description: "Pods should have a readiness probe."},
+				Violations: []check.Violation{{Reference: pod, Object: pod, Message: message}},
+			},
+		}
+	}
+
+	// Only the latest queued results are recorded
+	recorder.Queue(ruleResults("container web"), resolve)
+	recorder.Queue(ruleResults("container api"), resolve)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- recorder.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		events, err := client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+		require.NoError(t, err)
+		return len(events.Items) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	events, err := client.CoreV1().Events("foo").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "Pods should have a readiness probe. container api", events.Items[0].Message)
+	require.Equal(t, int32(1), events.Items[0].Count)
+	cancel()
+	require.NoError(t, <-errCh)
+}
@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format is the format the graph is exported in.
+type Format string
+
+const (
+	FormatDot     Format = "dot"
+	FormatJSON    Format = "json"
+	FormatGraphML Format = "graphml"
+)
+
+// ParseFormat returns the graph format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatDot, FormatJSON, FormatGraphML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown graph format: %s", s)
+	}
+}
+
+// Violation is a violation of a rule by a node, which is attached to the node when exporting the graph.
+type Violation struct {
+	RuleID   string `json:"rule"`
+	Severity uint   `json:"severity"`
+}
+
+// ExportNode is a node in the exported graph, identified by its reference ID.
+type ExportNode struct {
+	ID         string            `json:"id"`
+	Object     ObjectReference   `json:"object"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Violations []Violation       `json:"violations"`
+}
+
+// ExportEdge is an edge in the exported graph between the reference IDs of two nodes.
+type ExportEdge struct {
+	From string   `json:"from"`
+	To   string   `json:"to"`
+	Type EdgeType `json:"type"`
+}
+
+// ExportGraph is the graph as exported to JSON.
+type ExportGraph struct {
+	Nodes []ExportNode `json:"nodes"`
+	Edges []ExportEdge `json:"edges"`
+}
+
+// Encode encodes the graph in the format. The violations are keyed by the reference ID of the
+// node they are attached to, they are not included in the dot format.
+func (g *Graph) Encode(format Format, violations map[string][]Violation) ([]byte, error) {
+	switch format {
+	case FormatDot:
+		return g.EncodeDot()
+	case FormatJSON:
+		return g.EncodeJSON(violations)
+	case FormatGraphML:
+		return g.EncodeGraphML(violations)
+	default:
+		return nil, fmt.Errorf("unknown graph format: %s", format)
+	}
+}
+
+// Export returns the nodes sorted by reference ID with the violations attached, and the edges sorted by their nodes.
+func (g *Graph) Export(violations map[string][]Violation) ExportGraph {
+	exported := ExportGraph{
+		Nodes: []ExportNode{},
+		Edges: []ExportEdge{},
+	}
+	g.Iterate(func(node *Node) error {
+		nodeViolations := append([]Violation{}, violations[node.Reference.ID()]...)
+		sort.Slice(nodeViolations, func(i, j int) bool {
+			return nodeViolations[i].RuleID < nodeViolations[j].RuleID
+		})
+		exported.Nodes = append(exported.Nodes, ExportNode{
+			ID:         node.Reference.ID(),
+			Object:     node.Reference,
+			Labels:     node.Unstructured.GetLabels(),
+			Violations: nodeViolations,
+		})
+		return nil
+	})
+	edges := g.dg.Edges()
+	for edges.Next() {
+		edge := edges.Edge().(Edge)
+		exported.Edges = append(exported.Edges, ExportEdge{
+			From: edge.From().(*Node).Reference.ID(),
+			To:   edge.To().(*Node).Reference.ID(),
+			Type: edge.Type,
+		})
+	}
+	sort.Slice(exported.Nodes, func(i, j int) bool {
+		return exported.Nodes[i].ID < exported.Nodes[j].ID
+	})
+	sort.Slice(exported.Edges, func(i, j int) bool {
+		if exported.Edges[i].From != exported.Edges[j].From {
+			return exported.Edges[i].From < exported.Edges[j].From
+		}
+		return exported.Edges[i].To < exported.Edges[j].To
+	})
+	return exported
+}
+
+// EncodeJSON encodes the graph as JSON.
+func (g *Graph) EncodeJSON(violations map[string][]Violation) ([]byte, error) {
+	return json.MarshalIndent(g.Export(violations), "", "  ")
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// EncodeGraphML encodes the graph as GraphML. Labels and violation rule IDs are joined with commas
+// as GraphML attributes are scalar values, the highest severity of the violations is set as maxSeverity.
+func (g *Graph) EncodeGraphML(violations map[string][]Violation) ([]byte, error) {
+	exported := g.Export(violations)
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", AttrName: "label", AttrType: "string"},
+			{ID: "apiVersion", For: "node", AttrName: "apiVersion", AttrType: "string"},
+			{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: "namespace", For: "node", AttrName: "namespace", AttrType: "string"},
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "labels", For: "node", AttrName: "labels", AttrType: "string"},
+			{ID: "violations", For: "node", AttrName: "violations", AttrType: "string"},
+			{ID: "maxSeverity", For: "node", AttrName: "maxSeverity", AttrType: "int"},
+			{ID: "type", For: "edge", AttrName: "type", AttrType: "string"},
+		},
+	}
+	doc.Graph.ID = "Kubernetes"
+	doc.Graph.EdgeDefault = "directed"
+	for _, node := range exported.Nodes {
+		labels := []string{}
+		for key, value := range node.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		ruleIDs := []string{}
+		maxSeverity := uint(0)
+		for _, violation := range node.Violations {
+			ruleIDs = append(ruleIDs, violation.RuleID)
+			if violation.Severity > maxSeverity {
+				maxSeverity = violation.Severity
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: []graphMLData{
+				{Key: "label", Value: fmt.Sprintf("%s/%s", node.Object.Kind, node.Object.Name)},
+				{Key: "apiVersion", Value: node.Object.ApiVersion},
+				{Key: "kind", Value: node.Object.Kind},
+				{Key: "namespace", Value: node.Object.Namespace},
+				{Key: "name", Value: node.Object.Name},
+				{Key: "labels", Value: strings.Join(labels, ",")},
+				{Key: "violations", Value: strings.Join(ruleIDs, ",")},
+				{Key: "maxSeverity", Value: strconv.FormatUint(uint64(maxSeverity), 10)},
+			},
+		})
+	}
+	for i, edge := range exported.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     fmt.Sprintf("e%d", i),
+			Source: edge.From,
+			Target: edge.To,
+			Data:   []graphMLData{{Key: "type", Value: string(edge.Type)}},
+		})
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExport(t *testing.T) {
+	pod := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"serviceAccountName": "web"},
+	}}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetNamespace("foo")
+	pod.SetName("web")
+	pod.SetLabels(map[string]string{"app": "web"})
+	serviceAccount := unstructured.Unstructured{}
+	serviceAccount.SetAPIVersion("v1")
+	serviceAccount.SetKind("ServiceAccount")
+	serviceAccount.SetNamespace("foo")
+	serviceAccount.SetName("web")
+	g := NewGraph()
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{pod, serviceAccount}))
+	violations := map[string][]Violation{
+		"v1/Pod/foo/web": {{RuleID: "WithoutController", Severity: 8}, {RuleID: "MissingReadinessProbe", Severity: 5}},
+	}
+
+	b, err := g.Encode(FormatJSON, violations)
+	require.NoError(t, err)
+	exported := ExportGraph{}
+	require.NoError(t, json.Unmarshal(b, &exported))
+	require.Len(t, exported.Nodes, 2)
+	require.Equal(t, "v1/Pod/foo/web", exported.Nodes[0].ID)
+	require.Equal(t, map[string]string{"app": "web"}, exported.Nodes[0].Labels)
+	require.Equal(t, []Violation{{RuleID: "MissingReadinessProbe", Severity: 5}, {RuleID: "WithoutController", Severity: 8}}, exported.Nodes[0].Violations)
+	require.Empty(t, exported.Nodes[1].Violations)
+	require.Equal(t, []ExportEdge{{From: "v1/Pod/foo/web", To: "v1/ServiceAccount/foo/web", Type: EdgeTypeConsumes}}, exported.Edges)
+
+	b, err = g.Encode(FormatGraphML, violations)
+	require.NoError(t, err)
+	doc := graphML{}
+	require.NoError(t, xml.Unmarshal(b, &doc))
+	require.Len(t, doc.Graph.Nodes, 2)
+	require.Contains(t, doc.Graph.Nodes[0].Data, graphMLData{Key: "violations", Value: "MissingReadinessProbe,WithoutController"})
+	require.Contains(t, doc.Graph.Nodes[0].Data, graphMLData{Key: "maxSeverity", Value: "8"})
+	require.Len(t, doc.Graph.Edges, 1)
+	require.Equal(t, "v1/ServiceAccount/foo/web", doc.Graph.Edges[0].Target)
+
+	_, err = ParseFormat("png")
+	require.Error(t, err)
+}
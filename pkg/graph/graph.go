@@ -3,27 +3,52 @@ package graph
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/go-logr/logr"
-	"github.com/google/uuid"
 	"gonum.org/v1/gonum/graph/encoding/dot"
 	"gonum.org/v1/gonum/graph/simple"
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
 type Graph struct {
-	dg    *simple.DirectedGraph
-	idMap map[string]int64
+	dg     *simple.DirectedGraph
+	ids    *identities
+	gvkMap map[schema.GroupVersionKind]map[int64]*Node
+	gkMap  map[schema.GroupKind]map[int64]*Node
+	// serverVersion is the version of the cluster the graph was populated from.
+	serverVersion string
+	// resources are the resources discovered in the cluster the graph was populated from.
+	resources    []schema.GroupVersionResource
+	helmReleases map[string]*HelmRelease
+	// referrers are the nodes referring to a reference ID, and ownedBy the nodes with a controller
+	// owner reference to a UID, including references to objects which are not in the graph.
+	referrers map[string]map[int64]*Node
+	ownedBy   map[types.UID]map[int64]*Node
+	logger    logr.Logger
+
+	cacheMu sync.Mutex
+	// cache contains values derived from the graph, which are kept for the lifetime of the graph.
+	cache map[interface{}]interface{}
 }
 
 func NewGraph() *Graph {
 	return &Graph{
-		dg:    simple.NewDirectedGraph(),
-		idMap: map[string]int64{},
+		dg:           simple.NewDirectedGraph(),
+		ids:          newIdentities(),
+		gvkMap:       map[schema.GroupVersionKind]map[int64]*Node{},
+		gkMap:        map[schema.GroupKind]map[int64]*Node{},
+		helmReleases: map[string]*HelmRelease{},
+		referrers:    map[string]map[int64]*Node{},
+		ownedBy:      map[types.UID]map[int64]*Node{},
+		logger:       logr.Discard(),
+		cache:        map[interface{}]interface{}{},
 	}
 }
 
@@ -31,16 +56,125 @@ func NewGraph() *Graph {
 func (g *Graph) Populate(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, namespace string) error {
 	logger := logr.FromContextOrDiscard(ctx).WithName("graph")
 	logger.Info("discovering API resources")
-	gvrs, err := discover(ctx, client, namespace != "")
+	serverVersion, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("could not get server version: %w", err)
+	}
+	g.serverVersion = serverVersion.GitVersion
+	gvrs, err := DiscoverResources(ctx, client, namespace)
 	if err != nil {
-		return fmt.Errorf("could not discover API resources: %w", err)
+		return err
 	}
-	gvrs = filterGVRs(gvrs)
+	g.resources = gvrs
 	logger.Info("fetching all resources")
 	objects, err := fetch(ctx, dynamicClient, gvrs, namespace)
 	if err != nil {
 		return fmt.Errorf("could not fetch API resources: %w", err)
 	}
+	return g.populate(ctx, objects)
+}
+
+// Copy returns a copy of the graph which can be changed without affecting the graph. The nodes
+// are shared between the graphs, so the objects of the nodes must not be modified. Cached values
+// are not copied, as they may refer to the graph.
+func (g *Graph) Copy() *Graph {
+	c := &Graph{
+		dg: simple.NewDirectedGraph(),
+		ids: &identities{
+			nextID:     g.ids.nextID,
+			uids:       map[types.UID]int64{},
+			references: map[string]int64{},
+		},
+		gvkMap:        map[schema.GroupVersionKind]map[int64]*Node{},
+		gkMap:         map[schema.GroupKind]map[int64]*Node{},
+		serverVersion: g.serverVersion,
+		resources:     g.resources,
+		helmReleases:  map[string]*HelmRelease{},
+		referrers:     map[string]map[int64]*Node{},
+		ownedBy:       map[types.UID]map[int64]*Node{},
+		logger:        g.logger,
+		cache:         map[interface{}]interface{}{},
+	}
+	for uid, id := range g.ids.uids {
+		c.ids.uids[uid] = id
+	}
+	for reference, id := range g.ids.references {
+		c.ids.references[reference] = id
+	}
+	nodes := g.dg.Nodes()
+	for nodes.Next() {
+		node := nodes.Node().(*Node)
+		c.dg.AddNode(node)
+		c.index(node)
+	}
+	edges := g.dg.Edges()
+	for edges.Next() {
+		c.dg.SetEdge(edges.Edge())
+	}
+	for key, release := range g.helmReleases {
+		c.helmReleases[key] = release
+	}
+	for referenceID, referrers := range g.referrers {
+		c.referrers[referenceID] = map[int64]*Node{}
+		for id, node := range referrers {
+			c.referrers[referenceID][id] = node
+		}
+	}
+	for uid, referrers := range g.ownedBy {
+		c.ownedBy[uid] = map[int64]*Node{}
+		for id, node := range referrers {
+			c.ownedBy[uid][id] = node
+		}
+	}
+	return c
+}
+
+// Cached returns the value cached for the key, which is created and cached if it does not exist.
+// Keys have to be comparable, values are kept for the lifetime of the graph.
+func (g *Graph) Cached(key interface{}, create func() (interface{}, error)) (interface{}, error) {
+	g.cacheMu.Lock()
+	defer g.cacheMu.Unlock()
+	if value, ok := g.cache[key]; ok {
+		return value, nil
+	}
+	value, err := create()
+	if err != nil {
+		return nil, err
+	}
+	g.cache[key] = value
+	return value, nil
+}
+
+// ServerVersion returns the version of the cluster, empty if the graph was not populated from a cluster.
+func (g *Graph) ServerVersion() string {
+	return g.serverVersion
+}
+
+// Resources returns the resources discovered in the cluster, empty if the graph was not populated from a cluster.
+func (g *Graph) Resources() []schema.GroupVersionResource {
+	return g.resources
+}
+
+// Objects returns the objects of all nodes together with the secrets of the Helm releases, sorted by reference ID.
+func (g *Graph) Objects() []unstructured.Unstructured {
+	objects := []unstructured.Unstructured{}
+	nodes := g.dg.Nodes()
+	for nodes.Next() {
+		objects = append(objects, nodes.Node().(*Node).Unstructured)
+	}
+	for _, release := range g.helmReleases {
+		objects = append(objects, release.object)
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return ReferenceForObject(objects[i]).ID() < ReferenceForObject(objects[j]).ID()
+	})
+	return objects
+}
+
+// populate adds the objects as nodes and connects the edges between them.
+func (g *Graph) populate(ctx context.Context, objects []unstructured.Unstructured) error {
+	logger := logr.FromContextOrDiscard(ctx).WithName("graph")
+	g.logger = logger
 	logger.Info("adding nodes")
 	for _, u := range objects {
 		err := g.AddUnstructuredNode(u)
@@ -73,55 +207,70 @@ func (g *Graph) AddUnstructuredNode(u unstructured.Unstructured) error {
 	if node == nil {
 		return nil
 	}
+	return g.addNode(node)
+}
 
-	// Skip helm release secrets
-	// TODO: Find a better place to check this
-	if node.Reference.ApiVersion == "v1" && node.Reference.Kind == "Secret" && node.Object.(*corev1.Secret).Type == "helm.sh/release.v1" {
-		return nil
+// addNode adds a node to the graph, it is not connected to any other nodes.
+func (g *Graph) addNode(node *Node) error {
+	// Helm release secrets are not added as nodes, the objects in the release are checked separately
+	if isHelmReleaseSecret(node) {
+		return g.addHelmRelease(node)
 	}
 
 	// If the node id already exists, AddNode() will panic
-	_, ok := g.idMap[node.Reference.ID()]
-	if ok {
+	id, ok, err := g.ids.assign(node.UID(), node.Reference)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return nil
 	}
+	node.id = id
 
 	g.dg.AddNode(node)
-	g.idMap[node.Reference.ID()] = node.ID()
+	g.index(node)
 	return nil
 }
 
+// index adds the node to the kind lookup maps used by List.
+func (g *Graph) index(node *Node) {
+	gvk := node.Unstructured.GroupVersionKind()
+	if _, ok := g.gvkMap[gvk]; !ok {
+		g.gvkMap[gvk] = map[int64]*Node{}
+	}
+	g.gvkMap[gvk][node.ID()] = node
+	gk := gvk.GroupKind()
+	if _, ok := g.gkMap[gk]; !ok {
+		g.gkMap[gk] = map[int64]*Node{}
+	}
+	g.gkMap[gk][node.ID()] = node
+}
+
 // AddEdgesForNode adds all the edges for a specific node
 func (g *Graph) AddEdgesForNode(node *Node) error {
-	for _, ownerRef := range node.Unstructured.GetOwnerReferences() {
-		if ownerRef.Controller == nil {
-			continue
+	for _, uid := range controllerUIDs(node) {
+		if _, ok := g.ownedBy[uid]; !ok {
+			g.ownedBy[uid] = map[int64]*Node{}
 		}
-		if !*ownerRef.Controller {
+		g.ownedBy[uid][node.ID()] = node
+		// Owners can be outside of the graph when scoped to a namespace
+		id, ok := g.ids.byUID(uid)
+		if !ok {
 			continue
 		}
-		refId, err := uuid.Parse(string(ownerRef.UID))
-		if err != nil {
-			fmt.Println("owner ref", ownerRef.String())
-			return err
-		}
-		refNode := g.dg.Node(int64(refId.ID()))
-		if refNode == nil {
-			return fmt.Errorf("to node cannot be nil")
-		}
+		refNode := g.dg.Node(id)
 		edge := NewEdge(refNode, node, EdgeTypeOwner)
 		g.dg.SetEdge(edge)
 	}
 
-	relationships := relationshipsForObject(node.Object)
-	for _, relationship := range relationships {
-		if relationship.Reference.Namespace == "" {
-			relationship.Reference.Namespace = node.Reference.Namespace
+	for _, relationship := range nodeRelationships(node) {
+		if _, ok := g.referrers[relationship.Reference.ID()]; !ok {
+			g.referrers[relationship.Reference.ID()] = map[int64]*Node{}
 		}
-		id, ok := g.idMap[relationship.Reference.ID()]
+		g.referrers[relationship.Reference.ID()][node.ID()] = node
+		id, ok := g.ids.byReference(relationship.Reference.ID())
 		if !ok {
-			return nil
-			//return fmt.Errorf("missing reference: %s", relationship.Reference.ID())
+			continue
 		}
 		refNode := g.dg.Node(id)
 
@@ -138,9 +287,90 @@ func (g *Graph) AddEdgesForNode(node *Node) error {
 	return nil
 }
 
-// TODO: Implement
-func (g *Graph) List(gvk schema.GroupVersionKind) []Node {
-	return []Node{}
+// controllerUIDs returns the UIDs of the controller owner references of the node.
+func controllerUIDs(node *Node) []types.UID {
+	uids := []types.UID{}
+	for _, ownerRef := range node.Unstructured.GetOwnerReferences() {
+		if ownerRef.Controller == nil || !*ownerRef.Controller {
+			continue
+		}
+		uids = append(uids, ownerRef.UID)
+	}
+	return uids
+}
+
+// nodeRelationships returns the relationships of the node, references without a namespace are in the namespace of the node.
+func nodeRelationships(node *Node) []RelationshipDescription {
+	relationships := relationshipsForObject(node.Object)
+	for i := range relationships {
+		if relationships[i].Reference.Namespace == "" {
+			relationships[i].Reference.Namespace = node.Reference.Namespace
+		}
+	}
+	return relationships
+}
+
+// ListOptions filters the nodes returned when listing.
+type ListOptions struct {
+	// Namespace limits the nodes to a single namespace, all namespaces are returned when empty.
+	Namespace string
+	// Selector limits the nodes to the ones with matching labels, all nodes are returned when nil.
+	Selector labels.Selector
+}
+
+// List returns all nodes of a group version kind sorted by their reference.
+func (g *Graph) List(gvk schema.GroupVersionKind, opts ListOptions) []*Node {
+	return filterNodes(g.gvkMap[gvk], opts)
+}
+
+// ListGroupKind returns all nodes of a group kind regardless of version sorted by their reference.
+func (g *Graph) ListGroupKind(gk schema.GroupKind, opts ListOptions) []*Node {
+	return filterNodes(g.gkMap[gk], opts)
+}
+
+func filterNodes(nodeMap map[int64]*Node, opts ListOptions) []*Node {
+	nodes := []*Node{}
+	for _, node := range nodeMap {
+		if opts.Namespace != "" && node.Reference.Namespace != opts.Namespace {
+			continue
+		}
+		if opts.Selector != nil && !opts.Selector.Matches(labels.Set(node.Unstructured.GetLabels())) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Reference.ID() < nodes[j].Reference.ID()
+	})
+	return nodes
+}
+
+// NodeByUID returns the node of the object with the UID, nil is returned if it does not exist.
+func (g *Graph) NodeByUID(uid types.UID) *Node {
+	id, ok := g.ids.byUID(uid)
+	if !ok {
+		return nil
+	}
+	return g.dg.Node(id).(*Node)
+}
+
+// NodeByReferenceID returns the node with the reference ID, nil is returned if it does not exist.
+func (g *Graph) NodeByReferenceID(referenceID string) *Node {
+	id, ok := g.ids.byReference(referenceID)
+	if !ok {
+		return nil
+	}
+	return g.dg.Node(id).(*Node)
+}
+
+// ObjectUID returns the UID of the object with the reference, false is returned if the object
+// does not exist or was not read from a cluster.
+func (g *Graph) ObjectUID(reference ObjectReference) (types.UID, bool) {
+	node := g.NodeByReferenceID(reference.ID())
+	if node == nil || node.Unstructured.GetUID() == "" {
+		return "", false
+	}
+	return node.Unstructured.GetUID(), true
 }
 
 // Edges returns a list of all edges to and from a node
@@ -165,6 +395,11 @@ func (g *Graph) Edges(node *Node) []Edge {
 	return edges
 }
 
+// Size returns the number of nodes and edges in the graph.
+func (g *Graph) Size() (int, int) {
+	return g.dg.Nodes().Len(), g.dg.Edges().Len()
+}
+
 // Iterate lists all nodes in the graph
 func (g *Graph) Iterate(f func(n *Node) error) error {
 	nodes := g.dg.Nodes()
@@ -1,6 +1,94 @@
 package graph
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
 
 func TestFindRootOwner(t *testing.T) {
 }
+
+func TestList(t *testing.T) {
+	g := NewGraph()
+	for _, u := range []unstructured.Unstructured{
+		newTestObject("v1", "Service", "foo", "a", map[string]string{"app": "a"}),
+		newTestObject("v1", "Service", "foo", "b", map[string]string{"app": "b"}),
+		newTestObject("v1", "Service", "bar", "a", map[string]string{"app": "a"}),
+		newTestObject("policy/v1", "PodDisruptionBudget", "foo", "a", nil),
+		newTestObject("policy/v1beta1", "PodDisruptionBudget", "foo", "b", nil),
+	} {
+		require.NoError(t, g.AddUnstructuredNode(u))
+	}
+
+	nodes := g.List(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, ListOptions{})
+	require.Equal(t, []string{"v1/Service/bar/a", "v1/Service/foo/a", "v1/Service/foo/b"}, referenceIDs(nodes))
+
+	nodes = g.List(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, ListOptions{Namespace: "foo"})
+	require.Equal(t, []string{"v1/Service/foo/a", "v1/Service/foo/b"}, referenceIDs(nodes))
+
+	nodes = g.List(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, ListOptions{Selector: labels.SelectorFromSet(labels.Set{"app": "a"})})
+	require.Equal(t, []string{"v1/Service/bar/a", "v1/Service/foo/a"}, referenceIDs(nodes))
+
+	nodes = g.List(schema.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}, ListOptions{})
+	require.Equal(t, []string{"policy/v1/PodDisruptionBudget/foo/a"}, referenceIDs(nodes))
+
+	nodes = g.ListGroupKind(schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}, ListOptions{})
+	require.Equal(t, []string{"policy/v1/PodDisruptionBudget/foo/a", "policy/v1beta1/PodDisruptionBudget/foo/b"}, referenceIDs(nodes))
+
+	nodes = g.List(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, ListOptions{})
+	require.Empty(t, nodes)
+}
+
+func newTestObject(apiVersion, kind, namespace, name string, labels map[string]string) unstructured.Unstructured {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetLabels(labels)
+	return u
+}
+
+func referenceIDs(nodes []*Node) []string {
+	ids := []string{}
+	for _, node := range nodes {
+		ids = append(ids, node.Reference.ID())
+	}
+	return ids
+}
+
+func TestNodeIdentity(t *testing.T) {
+	g := NewGraph()
+	owner := newTestObject("apps/v1", "ReplicaSet", "foo", "bar", nil)
+	owner.SetUID("4e4f6b2a-0000-0000-0000-000000000001")
+	// Shares the first 32 bits with the owner UID
+	pod := newTestObject("v1", "Pod", "foo", "bar", nil)
+	pod.SetUID("4e4f6b2a-0000-0000-0000-000000000002")
+	controller := true
+	pod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar", UID: owner.GetUID(), Controller: &controller}})
+	require.NoError(t, g.AddUnstructuredNode(owner))
+	require.NoError(t, g.AddUnstructuredNode(pod))
+	// Adding the same object twice is ignored
+	require.NoError(t, g.AddUnstructuredNode(pod))
+
+	ownerNode := g.NodeByUID(owner.GetUID())
+	require.NotNil(t, ownerNode)
+	podNode := g.NodeByReferenceID("v1/Pod/foo/bar")
+	require.NotNil(t, podNode)
+	require.NotEqual(t, ownerNode.ID(), podNode.ID())
+	require.Nil(t, g.NodeByUID("missing"))
+	require.Nil(t, g.NodeByReferenceID("v1/Pod/foo/missing"))
+
+	require.NoError(t, g.AddEdgesForNode(podNode))
+	require.Equal(t, ownerNode, g.FindRootOwner(podNode))
+
+	// The same reference with a different UID is a collision
+	duplicate := newTestObject("v1", "Pod", "foo", "bar", nil)
+	duplicate.SetUID("4e4f6b2a-0000-0000-0000-000000000003")
+	require.Error(t, g.AddUnstructuredNode(duplicate))
+}
@@ -0,0 +1,171 @@
+package graph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	helmReleaseSecretType = "helm.sh/release.v1"
+	helmDeployedStatus    = "deployed"
+)
+
+// HelmRelease is the latest deployed revision of a Helm release.
+type HelmRelease struct {
+	Name         string
+	Namespace    string
+	Revision     int
+	Chart        string
+	ChartVersion string
+	// Objects are the objects in the rendered manifest of the release.
+	Objects []unstructured.Unstructured
+	// secret is the namespaced name of the secret the release was stored in.
+	secret string
+	// object is the secret the release was stored in.
+	object unstructured.Unstructured
+}
+
+// Reference returns a reference used to report on the release.
+func (h *HelmRelease) Reference() ObjectReference {
+	return ObjectReference{
+		ApiVersion: helmReleaseSecretType,
+		Kind:       "HelmRelease",
+		Namespace:  h.Namespace,
+		Name:       h.Name,
+	}
+}
+
+// helmReleasePayload is the subset of the Helm release stored in the release secret.
+type helmReleasePayload struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status string `json:"status"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Manifest string `json:"manifest"`
+}
+
+// isHelmReleaseSecret returns true if the object is a Secret used by Helm to store a release.
+func isHelmReleaseSecret(node *Node) bool {
+	secret, ok := node.Object.(*corev1.Secret)
+	return ok && secret.Type == helmReleaseSecretType
+}
+
+// IsHelmReleaseSecret returns true if the object is a Secret used by Helm to store a release.
+func IsHelmReleaseSecret(u unstructured.Unstructured) bool {
+	secretType, _, _ := unstructured.NestedString(u.Object, "type")
+	return u.GroupVersionKind() == corev1.SchemeGroupVersion.WithKind("Secret") && secretType == helmReleaseSecretType
+}
+
+// addHelmRelease keeps the release stored in the secret if it is the latest deployed revision.
+// A release which is no longer deployed is removed if it was stored in the same secret. Secrets which
+// cannot be decoded are logged and skipped, so a single broken release does not fail the whole graph.
+func (g *Graph) addHelmRelease(node *Node) error {
+	secret := node.Object.(*corev1.Secret)
+	// Avoid decoding releases which are not deployed
+	if status, ok := secret.Labels["status"]; ok && status != helmDeployedStatus {
+		g.removeHelmRelease(secret)
+		return nil
+	}
+	release, deployed, err := decodeHelmRelease(secret)
+	if err != nil {
+		g.logger.Error(err, "skipping helm release secret which could not be decoded", "namespace", secret.Namespace, "name", secret.Name)
+		g.removeHelmRelease(secret)
+		return nil
+	}
+	if !deployed {
+		g.removeHelmRelease(secret)
+		return nil
+	}
+	release.object = node.Unstructured
+	key := release.Reference().ID()
+	if current, ok := g.helmReleases[key]; ok && current.Revision >= release.Revision && current.secret != release.secret {
+		return nil
+	}
+	g.helmReleases[key] = release
+	return nil
+}
+
+// decodeHelmRelease decodes the release stored in a Helm release secret. The release
+// is base64 encoded and gzipped JSON, false is returned if it is not deployed.
+func decodeHelmRelease(secret *corev1.Secret) (*HelmRelease, bool, error) {
+	b, err := base64.StdEncoding.DecodeString(string(secret.Data["release"]))
+	if err != nil {
+		return nil, false, err
+	}
+	// Releases are not compressed by older versions of Helm
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, false, err
+		}
+		defer r.Close()
+		b, err = io.ReadAll(r)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	payload := helmReleasePayload{}
+	err = json.Unmarshal(b, &payload)
+	if err != nil {
+		return nil, false, err
+	}
+	if payload.Info.Status != helmDeployedStatus {
+		return nil, false, nil
+	}
+	manifests, err := decodeManifests(strings.NewReader(payload.Manifest), "")
+	if err != nil {
+		return nil, false, fmt.Errorf("could not decode manifest: %w", err)
+	}
+	objects := []unstructured.Unstructured{}
+	for _, m := range manifests {
+		objects = append(objects, m.Object)
+	}
+	release := &HelmRelease{
+		Name:         payload.Name,
+		Namespace:    payload.Namespace,
+		Revision:     payload.Version,
+		Chart:        payload.Chart.Metadata.Name,
+		ChartVersion: payload.Chart.Metadata.Version,
+		Objects:      objects,
+		secret:       secret.Namespace + "/" + secret.Name,
+	}
+	return release, true, nil
+}
+
+// removeHelmRelease removes the release stored in the secret, if it is the latest deployed revision.
+func (g *Graph) removeHelmRelease(secret *corev1.Secret) {
+	for key, release := range g.helmReleases {
+		if release.secret == secret.Namespace+"/"+secret.Name {
+			delete(g.helmReleases, key)
+		}
+	}
+}
+
+// HelmReleases returns the latest deployed revision of all Helm releases sorted by namespace and name.
+func (g *Graph) HelmReleases() []*HelmRelease {
+	releases := []*HelmRelease{}
+	for _, release := range g.helmReleases {
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Reference().ID() < releases[j].Reference().ID()
+	})
+	return releases
+}
@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestHelmReleases(t *testing.T) {
+	manifest := `---
+# Source: app/templates/pdb.yaml
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: app
+---
+# Source: app/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`
+	g := NewGraph()
+	for _, u := range []unstructured.Unstructured{
+		newHelmReleaseSecret(t, "app", 1, "superseded", manifest),
+		newHelmReleaseSecret(t, "app", 2, "deployed", manifest),
+		newHelmReleaseSecret(t, "app", 3, "failed", manifest),
+	} {
+		require.NoError(t, g.AddUnstructuredNode(u))
+	}
+
+	// Release secrets are not added as nodes
+	require.Nil(t, g.NodeByReferenceID("v1/Secret/default/sh.helm.release.v1.app.v2"))
+	releases := g.HelmReleases()
+	require.Len(t, releases, 1)
+	require.Equal(t, "app", releases[0].Name)
+	require.Equal(t, "default", releases[0].Namespace)
+	require.Equal(t, 2, releases[0].Revision)
+	require.Equal(t, "app", releases[0].Chart)
+	require.Equal(t, "1.0.0", releases[0].ChartVersion)
+	require.Len(t, releases[0].Objects, 2)
+	require.Equal(t, "policy/v1beta1", releases[0].Objects[0].GetAPIVersion())
+	require.Equal(t, "PodDisruptionBudget", releases[0].Objects[0].GetKind())
+
+	// Releases which cannot be decoded are skipped
+	broken := newHelmReleaseSecret(t, "broken", 1, "deployed", manifest)
+	broken.Object["data"] = map[string]interface{}{"release": base64.StdEncoding.EncodeToString([]byte("not a release"))}
+	require.NoError(t, g.AddUnstructuredNode(broken))
+	require.Len(t, g.HelmReleases(), 1)
+}
+
+func newHelmReleaseSecret(t *testing.T, name string, revision int, status, manifest string) unstructured.Unstructured {
+	t.Helper()
+	payload := helmReleasePayload{
+		Name:      name,
+		Namespace: "default",
+		Version:   revision,
+		Manifest:  manifest,
+	}
+	payload.Info.Status = status
+	payload.Chart.Metadata.Name = name
+	payload.Chart.Metadata.Version = "1.0.0"
+	b, err := json.Marshal(payload)
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err = w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	release := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	u := newTestObject("v1", "Secret", "default", fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision), map[string]string{
+		"owner":   "helm",
+		"name":    name,
+		"status":  status,
+		"version": fmt.Sprint(revision),
+	})
+	u.Object["type"] = helmReleaseSecretType
+	u.Object["data"] = map[string]interface{}{
+		"release": base64.StdEncoding.EncodeToString([]byte(release)),
+	}
+	return u
+}
@@ -0,0 +1,57 @@
+package graph
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// identities assigns unique node ids to objects and keeps track of
+// the mapping from object UIDs and references to node ids.
+type identities struct {
+	nextID     int64
+	uids       map[types.UID]int64
+	references map[string]int64
+}
+
+func newIdentities() *identities {
+	return &identities{
+		uids:       map[types.UID]int64{},
+		references: map[string]int64{},
+	}
+}
+
+// assign returns a new node id for the object. False is returned if the object
+// has already been assigned an id, and an error if the reference is already
+// assigned to an object with a different UID.
+func (i *identities) assign(uid types.UID, reference ObjectReference) (int64, bool, error) {
+	if id, ok := i.uids[uid]; ok {
+		return id, false, nil
+	}
+	if _, ok := i.references[reference.ID()]; ok {
+		return 0, false, fmt.Errorf("identity collision for %s with uid %s", reference.ID(), uid)
+	}
+	id := i.nextID
+	i.nextID++
+	i.uids[uid] = id
+	i.references[reference.ID()] = id
+	return id, true, nil
+}
+
+// release removes the id assigned to the object.
+func (i *identities) release(uid types.UID, reference ObjectReference) {
+	delete(i.uids, uid)
+	delete(i.references, reference.ID())
+}
+
+// byUID returns the node id for an object UID.
+func (i *identities) byUID(uid types.UID) (int64, bool) {
+	id, ok := i.uids[uid]
+	return id, ok
+}
+
+// byReference returns the node id for an object reference ID.
+func (i *identities) byReference(referenceID string) (int64, bool) {
+	id, ok := i.references[referenceID]
+	return id, ok
+}
@@ -26,7 +26,7 @@ import (
 )
 
 // discover returns all resources that are supported by the cluster.
-func discover(ctx context.Context, client kubernetes.Interface, namespaced bool) ([]schema.GroupVersionResource, error) {
+func discover(ctx context.Context, client kubernetes.Interface, namespaced bool, verbs []string) ([]schema.GroupVersionResource, error) {
 	logger := logr.FromContextOrDiscard(ctx).WithName("discover")
 	groupList, err := client.Discovery().ServerPreferredResources()
 	if err != nil {
@@ -49,9 +49,9 @@ func discover(ctx context.Context, client kubernetes.Interface, namespaced bool)
 				continue
 			}
 			storageVersionHash[res.StorageVersionHash] = true
-			// Skip resources which cant be listed
-			if !allowsList(res.Verbs) {
-				logger.V(1).Info("skipping non listable resource", "group", gv.Group, "version", gv.Version, "kind", res.Kind)
+			// Skip resources which cant be listed or lack the other required verbs
+			if !allowsVerbs(res.Verbs, append([]string{"list"}, verbs...)) {
+				logger.V(1).Info("skipping resource without required verbs", "group", gv.Group, "version", gv.Version, "kind", res.Kind)
 				continue
 			}
 			// Skip cluster wide resources if only discovering namespaced
@@ -66,13 +66,20 @@ func discover(ctx context.Context, client kubernetes.Interface, namespaced bool)
 	return gvrs, err
 }
 
-func allowsList(verbs metav1.Verbs) bool {
-	for _, verb := range verbs {
-		if verb == "list" {
-			return true
+func allowsVerbs(verbs metav1.Verbs, required []string) bool {
+	for _, requiredVerb := range required {
+		allowed := false
+		for _, verb := range verbs {
+			if verb == requiredVerb {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
 // filterGVRs removes GVRs that are either unwanted or technically
@@ -324,7 +331,13 @@ func relationshipsForObject(object runtime.Object) []RelationshipDescription {
 	case *networkingv1.Ingress:
 		ingress := object.(*networkingv1.Ingress)
 		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
 			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service == nil {
+					continue
+				}
 				relationship := RelationshipDescription{
 					Type:      EdgeTypeReference,
 					Direction: RelationshipDirectionTo,
@@ -416,6 +429,9 @@ func relationshipsForObject(object runtime.Object) []RelationshipDescription {
 		}
 	case *sourcev1.GitRepository:
 		repo := object.(*sourcev1.GitRepository)
+		if repo.Spec.SecretRef == nil {
+			break
+		}
 		relationships = append(relationships, RelationshipDescription{
 			Type:      EdgeTypeConsumes,
 			Direction: RelationshipDirectionTo,
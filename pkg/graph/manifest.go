@@ -0,0 +1,188 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// PopulateFromManifests fills the graph with the resources found in a directory of manifests.
+func (g *Graph) PopulateFromManifests(ctx context.Context, dir string) error {
+	logger := logr.FromContextOrDiscard(ctx).WithName("graph")
+	logger.Info("reading manifests", "path", dir)
+	manifests, err := readManifests(dir)
+	if err != nil {
+		return fmt.Errorf("could not read manifests: %w", err)
+	}
+	objects := []unstructured.Unstructured{}
+	for _, m := range manifests {
+		objects = append(objects, m.Object)
+	}
+	err = g.populate(ctx, objects)
+	if err != nil {
+		return err
+	}
+	// The first manifest of an object is used if it is defined multiple times
+	for _, m := range manifests {
+		node := g.NodeByReferenceID(ReferenceForObject(m.Object).ID())
+		if node == nil || node.Source.Path != "" {
+			continue
+		}
+		node.Source = m.Source
+	}
+	return nil
+}
+
+// Source is the location in a file that a object was read from.
+type Source struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+}
+
+type manifest struct {
+	Object unstructured.Unstructured
+	Source Source
+}
+
+// readManifests returns all objects in the YAML and JSON files in a directory.
+func readManifests(dir string) ([]manifest, error) {
+	manifests := []manifest{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Skip hidden directories like .git
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fileManifests, err := decodeManifests(f, path)
+		if err != nil {
+			return fmt.Errorf("could not decode %s: %w", path, err)
+		}
+		manifests = append(manifests, fileManifests...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// decodeManifests decodes a stream of YAML documents or JSON objects.
+// Documents which are not Kubernetes objects are skipped and List kinds
+// are expanded into their items.
+func decodeManifests(r io.Reader, path string) ([]manifest, error) {
+	docs, err := splitDocuments(r)
+	if err != nil {
+		return nil, err
+	}
+	manifests := []manifest{}
+	for _, doc := range docs {
+		source := Source{
+			Path: path,
+			Line: doc.line,
+		}
+		b, err := yaml.ToJSON(doc.content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", doc.line, err)
+		}
+		obj := map[string]interface{}{}
+		err = json.Unmarshal(b, &obj)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", doc.line, err)
+		}
+		u := unstructured.Unstructured{Object: obj}
+		if u.GetAPIVersion() == "" || u.GetKind() == "" {
+			continue
+		}
+		if !u.IsList() {
+			manifests = append(manifests, manifest{Object: u, Source: source})
+			continue
+		}
+		err = u.EachListItem(func(o runtime.Object) error {
+			item, ok := o.(*unstructured.Unstructured)
+			if !ok {
+				return fmt.Errorf("unexpected list item type %T", o)
+			}
+			if item.GetAPIVersion() == "" || item.GetKind() == "" {
+				return nil
+			}
+			manifests = append(manifests, manifest{Object: *item, Source: source})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return manifests, nil
+}
+
+type document struct {
+	content []byte
+	line    int
+}
+
+// splitDocuments splits a YAML stream into documents and keeps track of the
+// first line of content in each document. Documents without content are skipped.
+func splitDocuments(r io.Reader) ([]document, error) {
+	docs := []document{}
+	current := document{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if isDocumentSeparator(line) {
+			if current.line != 0 {
+				docs = append(docs, current)
+			}
+			current = document{}
+			continue
+		}
+		if trimmed := strings.TrimSpace(line); current.line == 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			current.line = lineNumber
+		}
+		current.content = append(current.content, line...)
+		current.content = append(current.content, '\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current.line != 0 {
+		docs = append(docs, current)
+	}
+	return docs, nil
+}
+
+// isDocumentSeparator returns true if the line is a YAML document separator, optionally followed by a comment.
+func isDocumentSeparator(line string) bool {
+	if !strings.HasPrefix(line, "---") {
+		return false
+	}
+	trimmed := strings.TrimSpace(line[3:])
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
@@ -0,0 +1,101 @@
+package graph
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPopulateFromManifests(t *testing.T) {
+	dir := t.TempDir()
+	multiDoc := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: foo
+  namespace: bar
+---
+# comment only document
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  namespace: bar
+spec:
+  serviceAccountName: foo
+`
+	list := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: foo
+    namespace: bar
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: baz
+    namespace: bar
+`
+	values := `replicaCount: 1
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.yaml"), []byte(multiDoc), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "list.yml"), []byte(list), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte(values), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# foo"), 0644))
+
+	g := NewGraph()
+	err := g.PopulateFromManifests(context.Background(), dir)
+	require.NoError(t, err)
+
+	ids := []string{}
+	err = g.Iterate(func(n *Node) error {
+		ids = append(ids, n.Reference.ID())
+		return nil
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		"v1/ServiceAccount/bar/foo",
+		"v1/Pod/bar/foo",
+		"v1/ConfigMap/bar/foo",
+		"v1/ConfigMap/bar/baz",
+	}, ids)
+
+	var pod *Node
+	err = g.Iterate(func(n *Node) error {
+		if n.Reference.Kind == "Pod" {
+			pod = n
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	edges := g.Edges(pod)
+	require.Len(t, edges, 1)
+	require.Equal(t, EdgeTypeConsumes, edges[0].Type)
+	require.Equal(t, Source{Path: filepath.Join(dir, "app.yaml"), Line: 9}, pod.Source)
+	configMap := g.NodeByReferenceID("v1/ConfigMap/bar/baz")
+	require.Equal(t, Source{Path: filepath.Join(dir, "nested", "list.yml"), Line: 1}, configMap.Source)
+}
+
+func TestNewNodeSyntheticUID(t *testing.T) {
+	manifests, err := decodeManifests(strings.NewReader(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: bar
+`), "")
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	first, err := NewNode(manifests[0].Object)
+	require.NoError(t, err)
+	second, err := NewNode(manifests[0].Object)
+	require.NoError(t, err)
+	require.NotEmpty(t, first.UID())
+	require.Equal(t, first.UID(), second.UID())
+}
@@ -6,13 +6,14 @@ import (
 	"github.com/google/uuid"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type ObjectReference struct {
-	ApiVersion string
-	Kind       string
-	Namespace  string
-	Name       string
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
 }
 
 func (o ObjectReference) ID() string {
@@ -23,36 +24,41 @@ func (o ObjectReference) GVK() string {
 	return strings.Join([]string{o.ApiVersion, o.Kind}, "/")
 }
 
+// ReferenceForObject returns the reference identifying the object in the graph.
+func ReferenceForObject(u unstructured.Unstructured) ObjectReference {
+	return ObjectReference{
+		ApiVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Namespace:  u.GetNamespace(),
+		Name:       u.GetName(),
+	}
+}
+
 type Node struct {
 	id           int64
+	uid          types.UID
 	Unstructured unstructured.Unstructured
 	Object       runtime.Object
 	Reference    ObjectReference
+	// Source is the file the object was read from, empty if the object was read from a cluster.
+	Source Source
 }
 
 func NewNode(u unstructured.Unstructured) (*Node, error) {
-	reference := ObjectReference{
-		ApiVersion: u.GetAPIVersion(),
-		Kind:       u.GetKind(),
-		Namespace:  u.GetNamespace(),
-		Name:       u.GetName(),
-	}
+	reference := ReferenceForObject(u)
 
 	object, err := parseRuntimeObject(u)
 	if err != nil {
 		return nil, err
 	}
-	if string(u.GetUID()) == "" {
-		// return nil, fmt.Errorf("resource uuid is empty: %s", reference.ID())
-		return nil, nil
-	}
-	id, err := uuid.Parse(string(u.GetUID()))
-	if err != nil {
-		return nil, err
+	// Objects read from manifests do not have a UID, derive a stable one from the reference instead
+	uid := u.GetUID()
+	if uid == "" {
+		uid = types.UID(uuid.NewSHA1(uuid.NameSpaceOID, []byte(reference.ID())).String())
 	}
 
 	node := &Node{
-		id:           int64(id.ID()),
+		uid:          uid,
 		Unstructured: u,
 		Object:       object,
 		Reference:    reference,
@@ -60,10 +66,16 @@ func NewNode(u unstructured.Unstructured) (*Node, error) {
 	return node, nil
 }
 
+// ID returns the id of the node in the graph.
 func (n *Node) ID() int64 {
 	return n.id
 }
 
+// UID returns the UID of the object, or a synthetic UID if the object does not have one.
+func (n *Node) UID() types.UID {
+	return n.uid
+}
+
 func (n *Node) DOTID() string {
 	return n.Reference.ID()
 }
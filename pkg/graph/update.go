@@ -0,0 +1,205 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DiscoverResources returns the resources in the cluster which are added to the graph,
+// cluster wide resources are skipped when scoped to a namespace. Resources have to allow
+// listing together with the verbs, if any.
+func DiscoverResources(ctx context.Context, client kubernetes.Interface, namespace string, verbs ...string) ([]schema.GroupVersionResource, error) {
+	gvrs, err := discover(ctx, client, namespace != "", verbs)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover API resources: %w", err)
+	}
+	return filterGVRs(gvrs), nil
+}
+
+// PopulateFromObjects fills the graph with objects read from a cluster with the server version.
+func (g *Graph) PopulateFromObjects(ctx context.Context, serverVersion string, objects []unstructured.Unstructured) error {
+	g.serverVersion = serverVersion
+	return g.populate(ctx, objects)
+}
+
+// UpsertObject adds the object to the graph, replacing the object with the same UID if it exists,
+// and reconnects the edges of the nodes which refer to it. The node of the object is returned
+// together with the nodes connected to it before and after the change, no nodes are returned if
+// the object has not changed.
+func (g *Graph) UpsertObject(u unstructured.Unstructured) ([]*Node, error) {
+	node, err := NewNode(u)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, nil
+	}
+	if isHelmReleaseSecret(node) {
+		return nil, g.addHelmRelease(node)
+	}
+
+	current := g.findNode(node)
+	if current != nil && current.Unstructured.GetResourceVersion() != "" && current.Unstructured.GetResourceVersion() == u.GetResourceVersion() {
+		return nil, nil
+	}
+	return g.replaceNode(current, node)
+}
+
+// WithObject adds the object to the graph while f is called with its node, replacing the object with
+// the same UID if it exists. The graph is restored to its previous state once f has returned.
+func (g *Graph) WithObject(u unstructured.Unstructured, f func(node *Node) error) error {
+	node, err := NewNode(u)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("object %s cannot be added to the graph", ReferenceForObject(u).ID())
+	}
+	if isHelmReleaseSecret(node) {
+		return fmt.Errorf("helm release secrets cannot be added to the graph")
+	}
+	current := g.findNode(node)
+	_, err = g.replaceNode(current, node)
+	if err != nil {
+		return err
+	}
+	fErr := f(node)
+	if current == nil {
+		g.removeNode(node)
+		return fErr
+	}
+	_, err = g.replaceNode(node, current)
+	if err != nil {
+		return err
+	}
+	return fErr
+}
+
+// findNode returns the node in the graph with the same UID or reference as the node.
+func (g *Graph) findNode(node *Node) *Node {
+	current := g.NodeByUID(node.UID())
+	// The object may have been recreated without the deletion being observed
+	if current == nil {
+		current = g.NodeByReferenceID(node.Reference.ID())
+	}
+	return current
+}
+
+// replaceNode replaces the current node, which is nil if it does not exist, and reconnects the edges
+// of the nodes which refer to the node. The node is returned together with the nodes connected
+// to it before and after the change.
+func (g *Graph) replaceNode(current, node *Node) ([]*Node, error) {
+	affected := map[int64]*Node{}
+	if current != nil {
+		for _, n := range g.neighbours(current) {
+			affected[n.ID()] = n
+		}
+		g.removeNode(current)
+	}
+	err := g.addNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	// Edges are only added by the nodes which refer to the other node by reference or owner UID
+	for _, n := range append([]*Node{node}, g.nodeReferrers(node)...) {
+		err := g.AddEdgesForNode(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, n := range g.neighbours(node) {
+		affected[n.ID()] = n
+	}
+	delete(affected, node.ID())
+	return append([]*Node{node}, sortNodes(affected)...), nil
+}
+
+// DeleteObject removes the object from the graph and returns the nodes which were connected to it.
+func (g *Graph) DeleteObject(u unstructured.Unstructured) ([]*Node, error) {
+	node, err := NewNode(u)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, nil
+	}
+	if isHelmReleaseSecret(node) {
+		g.removeHelmRelease(node.Object.(*corev1.Secret))
+		return nil, nil
+	}
+	current := g.findNode(node)
+	if current == nil {
+		return nil, nil
+	}
+	affected := map[int64]*Node{}
+	for _, n := range g.neighbours(current) {
+		affected[n.ID()] = n
+	}
+	g.removeNode(current)
+	return sortNodes(affected), nil
+}
+
+// nodeReferrers returns the nodes which refer to the node by its reference or as controller owner.
+func (g *Graph) nodeReferrers(node *Node) []*Node {
+	referrers := map[int64]*Node{}
+	for id, n := range g.referrers[node.Reference.ID()] {
+		referrers[id] = n
+	}
+	for id, n := range g.ownedBy[node.UID()] {
+		referrers[id] = n
+	}
+	delete(referrers, node.ID())
+	return sortNodes(referrers)
+}
+
+// removeNode removes the node and all of its edges.
+func (g *Graph) removeNode(node *Node) {
+	g.dg.RemoveNode(node.ID())
+	for _, uid := range controllerUIDs(node) {
+		delete(g.ownedBy[uid], node.ID())
+		if len(g.ownedBy[uid]) == 0 {
+			delete(g.ownedBy, uid)
+		}
+	}
+	for _, relationship := range nodeRelationships(node) {
+		delete(g.referrers[relationship.Reference.ID()], node.ID())
+		if len(g.referrers[relationship.Reference.ID()]) == 0 {
+			delete(g.referrers, relationship.Reference.ID())
+		}
+	}
+	gvk := node.Unstructured.GroupVersionKind()
+	delete(g.gvkMap[gvk], node.ID())
+	delete(g.gkMap[gvk.GroupKind()], node.ID())
+	g.ids.release(node.UID(), node.Reference)
+}
+
+// neighbours returns the nodes with edges to or from the node.
+func (g *Graph) neighbours(node *Node) []*Node {
+	nodes := []*Node{}
+	for _, edge := range g.Edges(node) {
+		if edge.From().ID() == node.ID() {
+			nodes = append(nodes, edge.To().(*Node))
+			continue
+		}
+		nodes = append(nodes, edge.From().(*Node))
+	}
+	return nodes
+}
+
+func sortNodes(nodeMap map[int64]*Node) []*Node {
+	nodes := []*Node{}
+	for _, node := range nodeMap {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Reference.ID() < nodes[j].Reference.ID()
+	})
+	return nodes
+}
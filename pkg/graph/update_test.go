@@ -0,0 +1,151 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestUpsertAndDeleteObject(t *testing.T) {
+	g := NewGraph()
+	controller := true
+	pod := newTestObject("v1", "Pod", "foo", "bar-abc", nil)
+	pod.SetUID("00000000-0000-0000-0000-000000000002")
+	pod.SetResourceVersion("1")
+	pod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar", UID: "00000000-0000-0000-0000-000000000001", Controller: &controller}})
+	nodes, err := g.UpsertObject(pod)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1/Pod/foo/bar-abc"}, referenceIDs(nodes))
+
+	// The pod is connected once its owner is added
+	owner := newTestObject("apps/v1", "ReplicaSet", "foo", "bar", nil)
+	owner.SetUID("00000000-0000-0000-0000-000000000001")
+	owner.SetResourceVersion("1")
+	nodes, err = g.UpsertObject(owner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"apps/v1/ReplicaSet/foo/bar", "v1/Pod/foo/bar-abc"}, referenceIDs(nodes))
+	require.Equal(t, "apps/v1/ReplicaSet/foo/bar", g.FindRootOwner(g.NodeByUID(pod.GetUID())).Reference.ID())
+
+	// Unchanged objects do not affect any nodes
+	nodes, err = g.UpsertObject(owner)
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+
+	// Updated objects keep their edges
+	owner = *owner.DeepCopy()
+	owner.SetResourceVersion("2")
+	owner.SetLabels(map[string]string{"app": "bar"})
+	nodes, err = g.UpsertObject(owner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"apps/v1/ReplicaSet/foo/bar", "v1/Pod/foo/bar-abc"}, referenceIDs(nodes))
+	require.Equal(t, map[string]string{"app": "bar"}, g.NodeByUID(owner.GetUID()).Unstructured.GetLabels())
+	require.Len(t, g.Edges(g.NodeByUID(pod.GetUID())), 1)
+
+	nodes, err = g.DeleteObject(owner)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1/Pod/foo/bar-abc"}, referenceIDs(nodes))
+	require.Nil(t, g.NodeByUID(owner.GetUID()))
+	require.Nil(t, g.NodeByReferenceID("apps/v1/ReplicaSet/foo/bar"))
+	require.Empty(t, g.Edges(g.NodeByUID(pod.GetUID())))
+	require.Empty(t, g.ListGroupKind(owner.GroupVersionKind().GroupKind(), ListOptions{}))
+
+	// Recreated objects replace the object with the same reference
+	owner = *owner.DeepCopy()
+	owner.SetUID("00000000-0000-0000-0000-000000000003")
+	_, err = g.UpsertObject(owner)
+	require.NoError(t, err)
+	require.NotNil(t, g.NodeByUID(owner.GetUID()))
+}
+
+func TestWithObject(t *testing.T) {
+	g := NewGraph()
+	controller := true
+	owner := newTestObject("apps/v1", "ReplicaSet", "foo", "bar", nil)
+	owner.SetUID("00000000-0000-0000-0000-000000000001")
+	pod := newTestObject("v1", "Pod", "foo", "bar-abc", nil)
+	pod.SetUID("00000000-0000-0000-0000-000000000002")
+	pod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar", UID: owner.GetUID(), Controller: &controller}})
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{owner, pod}))
+
+	// A new object is connected while added and removed afterwards
+	newPod := newTestObject("v1", "Pod", "foo", "bar-def", nil)
+	newPod.SetOwnerReferences(pod.GetOwnerReferences())
+	err := g.WithObject(newPod, func(node *Node) error {
+		require.Equal(t, "apps/v1/ReplicaSet/foo/bar", g.FindRootOwner(node).Reference.ID())
+		return nil
+	})
+	require.NoError(t, err)
+	require.Nil(t, g.NodeByReferenceID("v1/Pod/foo/bar-def"))
+	require.Len(t, g.Edges(g.NodeByUID(owner.GetUID())), 1)
+
+	// An existing object is replaced while added and restored afterwards
+	updatedPod := *pod.DeepCopy()
+	updatedPod.SetLabels(map[string]string{"app": "bar"})
+	updatedPod.SetOwnerReferences(nil)
+	err = g.WithObject(updatedPod, func(node *Node) error {
+		require.Equal(t, "bar", node.Unstructured.GetLabels()["app"])
+		require.Empty(t, g.Edges(node))
+		return nil
+	})
+	require.NoError(t, err)
+	node := g.NodeByUID(pod.GetUID())
+	require.Empty(t, node.Unstructured.GetLabels())
+	require.Len(t, g.Edges(node), 1)
+}
+
+func TestUpsertObjectReconnectsReferrers(t *testing.T) {
+	g := NewGraph()
+	pod := newTestObject("v1", "Pod", "foo", "web", nil)
+	pod.Object["spec"] = map[string]interface{}{"serviceAccountName": "web"}
+	pod.SetUID("00000000-0000-0000-0000-000000000001")
+	other := newTestObject("v1", "Pod", "foo", "other", nil)
+	other.SetUID("00000000-0000-0000-0000-000000000002")
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "", []unstructured.Unstructured{pod, other}))
+
+	// The pod refers to the service account before it exists
+	serviceAccount := newTestObject("v1", "ServiceAccount", "foo", "web", nil)
+	serviceAccount.SetUID("00000000-0000-0000-0000-000000000003")
+	nodes, err := g.UpsertObject(serviceAccount)
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1/ServiceAccount/foo/web", "v1/Pod/foo/web"}, referenceIDs(nodes))
+	require.Len(t, g.Edges(g.NodeByUID(pod.GetUID())), 1)
+	require.Empty(t, g.Edges(g.NodeByUID(other.GetUID())))
+
+	// Deleted nodes no longer refer to other nodes
+	_, err = g.DeleteObject(pod)
+	require.NoError(t, err)
+	require.Empty(t, g.nodeReferrers(g.NodeByUID(serviceAccount.GetUID())))
+	require.NotContains(t, g.referrers, "v1/ServiceAccount/foo/web")
+}
+
+func TestCopy(t *testing.T) {
+	g := NewGraph()
+	controller := true
+	owner := newTestObject("apps/v1", "ReplicaSet", "foo", "bar", nil)
+	owner.SetUID("00000000-0000-0000-0000-000000000001")
+	pod := newTestObject("v1", "Pod", "foo", "bar-abc", nil)
+	pod.SetUID("00000000-0000-0000-0000-000000000002")
+	pod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bar", UID: owner.GetUID(), Controller: &controller}})
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "v1.22.0", []unstructured.Unstructured{owner, pod}))
+
+	c := g.Copy()
+	require.Equal(t, "v1.22.0", c.ServerVersion())
+	require.Equal(t, "apps/v1/ReplicaSet/foo/bar", c.FindRootOwner(c.NodeByUID(pod.GetUID())).Reference.ID())
+
+	// Changes to the copy do not affect the graph
+	newPod := newTestObject("v1", "Pod", "foo", "bar-def", nil)
+	newPod.SetUID("00000000-0000-0000-0000-000000000003")
+	newPod.SetOwnerReferences(pod.GetOwnerReferences())
+	_, err := c.UpsertObject(newPod)
+	require.NoError(t, err)
+	_, err = c.DeleteObject(pod)
+	require.NoError(t, err)
+	require.Len(t, c.Edges(c.NodeByUID(owner.GetUID())), 1)
+	require.Nil(t, g.NodeByUID(newPod.GetUID()))
+	require.NotNil(t, g.NodeByUID(pod.GetUID()))
+	require.Len(t, g.Edges(g.NodeByUID(owner.GetUID())), 1)
+	require.Len(t, g.ListGroupKind(pod.GroupVersionKind().GroupKind(), ListOptions{}), 1)
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+)
+
+const namespace = "kube_checker"
+
+// Source provides the current state which is exposed as metrics when scraped.
+type Source interface {
+	Results() map[string]*check.RuleResult
+	GraphSize() (nodes int, edges int)
+}
+
+// Metrics exposes the results of a source together with the duration and errors of evaluations.
+type Metrics struct {
+	registry           *prometheus.Registry
+	evaluationDuration *prometheus.HistogramVec
+	evaluationErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		evaluationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "evaluation_duration_seconds",
+			Help:      "Duration of rule evaluations, either of all objects or of the objects affected by a change.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"scope"}),
+		evaluationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "evaluation_errors_total",
+			Help:      "Number of rule evaluations which returned an error.",
+		}, []string{"scope"}),
+	}
+	m.registry.MustRegister(
+		m.evaluationDuration,
+		m.evaluationErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// RegisterSource exposes the results and graph size of the source.
+func (m *Metrics) RegisterSource(source Source) error {
+	return m.registry.Register(newCollector(source))
+}
+
+// ObserveEvaluation records the duration and result of an evaluation.
+func (m *Metrics) ObserveEvaluation(scope string, duration time.Duration, err error) {
+	m.evaluationDuration.WithLabelValues(scope).Observe(duration.Seconds())
+	if err != nil {
+		m.evaluationErrors.WithLabelValues(scope).Inc()
+	}
+}
+
+// Handler returns the handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// collector computes the gauges from the results of the source when scraped, so the
+// results do not have to be aggregated on every change.
+type collector struct {
+	source         Source
+	violationsDesc *prometheus.Desc
+	suppressedDesc *prometheus.Desc
+	deprecatedDesc *prometheus.Desc
+	graphNodesDesc *prometheus.Desc
+	graphEdgesDesc *prometheus.Desc
+}
+
+func newCollector(source Source) *collector {
+	return &collector{
+		source: source,
+		violationsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "violations"),
+			"Number of violations by rule and object.",
+			[]string{"rule_id", "severity", "namespace", "kind"}, nil,
+		),
+		suppressedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "suppressed_violations"),
+			"Number of violations suppressed by ignore annotations by rule and object.",
+			[]string{"rule_id", "severity", "namespace", "kind"}, nil,
+		),
+		deprecatedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "deprecated_objects"),
+			"Number of objects using deprecated api versions.",
+			[]string{"api_version", "kind", "removed_in"}, nil,
+		),
+		graphNodesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "graph", "nodes"),
+			"Number of objects in the graph.",
+			nil, nil,
+		),
+		graphEdgesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "graph", "edges"),
+			"Number of edges between objects in the graph.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.violationsDesc
+	ch <- c.suppressedDesc
+	ch <- c.deprecatedDesc
+	ch <- c.graphNodesDesc
+	ch <- c.graphEdgesDesc
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	violations := counter{}
+	suppressed := counter{}
+	deprecated := counter{}
+	for _, result := range c.source.Results() {
+		severity := fmt.Sprint(result.Rule.Severity)
+		for _, violation := range result.Violations {
+			violations.inc(result.Rule.ID, severity, violation.Object.Namespace, violation.Object.Kind)
+			if deprecation := result.Rule.Deprecation; deprecation != nil {
+				deprecated.inc(deprecation.ApiVersion, deprecation.Kind, deprecation.RemovedIn)
+			}
+		}
+		for _, violation := range result.Suppressed {
+			suppressed.inc(result.Rule.ID, severity, violation.Object.Namespace, violation.Object.Kind)
+		}
+	}
+	violations.collect(ch, c.violationsDesc)
+	suppressed.collect(ch, c.suppressedDesc)
+	deprecated.collect(ch, c.deprecatedDesc)
+
+	nodes, edges := c.source.GraphSize()
+	ch <- prometheus.MustNewConstMetric(c.graphNodesDesc, prometheus.GaugeValue, float64(nodes))
+	ch <- prometheus.MustNewConstMetric(c.graphEdgesDesc, prometheus.GaugeValue, float64(edges))
+}
+
+// counter counts occurrences of label values.
+type counter map[string]int
+
+func (c counter) inc(labelValues ...string) {
+	c[strings.Join(labelValues, "\x00")]++
+}
+
+func (c counter) collect(ch chan<- prometheus.Metric, desc *prometheus.Desc) {
+	keys := []string{}
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(c[key]), strings.Split(key, "\x00")...)
+	}
+}
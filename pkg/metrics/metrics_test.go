@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+type testSource struct {
+	results map[string]*check.RuleResult
+}
+
+func (s testSource) Results() map[string]*check.RuleResult {
+	return s.results
+}
+
+func (s testSource) GraphSize() (int, int) {
+	return 3, 2
+}
+
+func TestCollector(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "debug"}
+	ingress := graph.ObjectReference{ApiVersion: "extensions/v1beta1", Kind: "Ingress", Namespace: "foo", Name: "web"}
+	source := testSource{
+		results: map[string]*check.RuleResult{
+			"WithoutController": {
+				Rule:       check.Rule{ID: "WithoutController", Severity: 8},
+				Violations: []check.Violation{{Reference: pod, Object: pod}},
+			},
+			"APIVersionRemoved/extensions/v1beta1/Ingress": {
+				Rule: check.Rule{
+					ID:          "APIVersionRemoved/extensions/v1beta1/Ingress",
+					Severity:    10,
+					Deprecation: &check.Deprecation{ApiVersion: "extensions/v1beta1", Kind: "Ingress", RemovedIn: "v1.22"},
+				},
+				Violations: []check.Violation{{Reference: ingress, Object: ingress}},
+			},
+		},
+	}
+
+	expected := `
+# HELP kube_checker_deprecated_objects Number of objects using deprecated api versions.
+# TYPE kube_checker_deprecated_objects gauge
+kube_checker_deprecated_objects{api_version="extensions/v1beta1",kind="Ingress",removed_in="v1.22"} 1
+# HELP kube_checker_graph_nodes Number of objects in the graph.
+# TYPE kube_checker_graph_nodes gauge
+kube_checker_graph_nodes 3
+# HELP kube_checker_violations Number of violations by rule and object.
+# TYPE kube_checker_violations gauge
+kube_checker_violations{kind="Ingress",namespace="foo",rule_id="APIVersionRemoved/extensions/v1beta1/Ingress",severity="10"} 1
+kube_checker_violations{kind="Pod",namespace="foo",rule_id="WithoutController",severity="8"} 1
+`
+	err := testutil.CollectAndCompare(newCollector(source), strings.NewReader(expected), "kube_checker_violations", "kube_checker_deprecated_objects", "kube_checker_graph_nodes")
+	require.NoError(t, err)
+}
@@ -0,0 +1,276 @@
+package policyreport
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+)
+
+const (
+	// Name is the name of the reports written by kube-checker.
+	Name = "kube-checker"
+	// Source is the source set on every result.
+	Source = "kube-checker"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "kube-checker"
+)
+
+var (
+	GroupVersion                = schema.GroupVersion{Group: "wgpolicyk8s.io", Version: "v1alpha2"}
+	PolicyReportResource        = GroupVersion.WithResource("policyreports")
+	ClusterPolicyReportResource = GroupVersion.WithResource("clusterpolicyreports")
+)
+
+// Summary is the number of results of each kind.
+type Summary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+// Result is a single result of a policy report.
+type Result struct {
+	Source     string                   `json:"source"`
+	Policy     string                   `json:"policy"`
+	Rule       string                   `json:"rule,omitempty"`
+	Severity   string                   `json:"severity,omitempty"`
+	Result     string                   `json:"result"`
+	Message    string                   `json:"message,omitempty"`
+	Resources  []corev1.ObjectReference `json:"resources,omitempty"`
+	Properties map[string]string        `json:"properties,omitempty"`
+	Scored     bool                     `json:"scored"`
+}
+
+// Publisher writes the results as PolicyReports for each namespace and a ClusterPolicyReport
+// for cluster wide objects. Reports of namespaces without results are removed.
+type Publisher struct {
+	client dynamic.Interface
+	// namespace limits the reports to a single namespace, the ClusterPolicyReport is not written when set.
+	namespace string
+}
+
+func NewPublisher(client dynamic.Interface, namespace string) *Publisher {
+	return &Publisher{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Publish creates, updates or deletes the reports to match the results.
+func (p *Publisher) Publish(ctx context.Context, ruleResults map[string]*check.RuleResult) error {
+	namespaceResults := newResults(ruleResults)
+
+	existing, err := p.client.Resource(PolicyReportResource).Namespace(p.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", managedByLabel, managedByValue),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list policy reports: %w", err)
+	}
+	for _, report := range existing.Items {
+		if _, ok := namespaceResults[report.GetNamespace()]; ok && report.GetName() == Name {
+			continue
+		}
+		err := p.client.Resource(PolicyReportResource).Namespace(report.GetNamespace()).Delete(ctx, report.GetName(), metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("could not delete policy report %s/%s: %w", report.GetNamespace(), report.GetName(), err)
+		}
+	}
+
+	namespaces := []string{}
+	for namespace := range namespaceResults {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		if namespace == "" {
+			continue
+		}
+		err := p.apply(ctx, p.client.Resource(PolicyReportResource).Namespace(namespace), "PolicyReport", namespace, namespaceResults[namespace])
+		if err != nil {
+			return err
+		}
+	}
+	if p.namespace != "" {
+		return nil
+	}
+	client := p.client.Resource(ClusterPolicyReportResource)
+	if results, ok := namespaceResults[""]; ok {
+		return p.apply(ctx, client, "ClusterPolicyReport", "", results)
+	}
+	err = client.Delete(ctx, Name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("could not delete cluster policy report %s: %w", Name, err)
+	}
+	return nil
+}
+
+// apply creates the report or updates it if the results have changed.
+func (p *Publisher) apply(ctx context.Context, client dynamic.ResourceInterface, kind, namespace string, results []Result) error {
+	desired, err := newReport(kind, namespace, results)
+	if err != nil {
+		return err
+	}
+	current, err := client.Get(ctx, Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err := client.Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not create %s %s: %w", kind, reportName(namespace), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get %s %s: %w", kind, reportName(namespace), err)
+	}
+	if reflect.DeepEqual(current.Object["results"], desired.Object["results"]) && reflect.DeepEqual(current.Object["summary"], desired.Object["summary"]) {
+		return nil
+	}
+	current.Object["results"] = desired.Object["results"]
+	current.Object["summary"] = desired.Object["summary"]
+	_, err = client.Update(ctx, current, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not update %s %s: %w", kind, reportName(namespace), err)
+	}
+	return nil
+}
+
+func reportName(namespace string) string {
+	if namespace == "" {
+		return Name
+	}
+	return namespace + "/" + Name
+}
+
+func newReport(kind, namespace string, results []Result) (*unstructured.Unstructured, error) {
+	summary := Summary{}
+	for _, result := range results {
+		switch result.Result {
+		case "fail":
+			summary.Fail++
+		case "skip":
+			summary.Skip++
+		}
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&struct {
+		Summary Summary  `json:"summary"`
+		Results []Result `json:"results"`
+	}{
+		Summary: summary,
+		Results: results,
+	})
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion(GroupVersion.String())
+	u.SetKind(kind)
+	u.SetName(Name)
+	u.SetNamespace(namespace)
+	u.SetLabels(map[string]string{managedByLabel: managedByValue})
+	return u, nil
+}
+
+// newResults returns the report results of the violations keyed by the namespace of the
+// violating object, cluster wide objects are keyed by an empty string.
+func newResults(ruleResults map[string]*check.RuleResult) map[string][]Result {
+	namespaceResults := map[string][]Result{}
+	add := func(rule check.Rule, violation check.Violation, result string) {
+		namespace := violation.Reference.Namespace
+		namespaceResults[namespace] = append(namespaceResults[namespace], newResult(rule, violation, result))
+	}
+	for _, ruleResult := range ruleResults {
+		for _, violation := range ruleResult.Violations {
+			add(ruleResult.Rule, violation, "fail")
+		}
+		for _, violation := range ruleResult.Suppressed {
+			add(ruleResult.Rule, violation, "skip")
+		}
+	}
+	for _, results := range namespaceResults {
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].Rule != results[j].Rule {
+				return results[i].Rule < results[j].Rule
+			}
+			return resourceID(results[i]) < resourceID(results[j])
+		})
+	}
+	return namespaceResults
+}
+
+// newResult maps the violation of a rule to a result. The policy is the rule ID up to the first
+// slash, so all deprecations of the same kind are grouped by policy.
+func newResult(rule check.Rule, violation check.Violation, result string) Result {
+	message := rule.Description
+	if violation.Message != "" {
+		message = fmt.Sprintf("%s %s", message, violation.Message)
+	}
+	properties := map[string]string{}
+	if rule.Link != "" {
+		properties["link"] = rule.Link
+	}
+	if violation.Object != violation.Reference {
+		properties["object"] = violation.Object.ID()
+	}
+	if violation.Suppression != nil && violation.Suppression.Reason != "" {
+		properties["suppressionReason"] = violation.Suppression.Reason
+	}
+	if len(properties) == 0 {
+		properties = nil
+	}
+	return Result{
+		Source:   Source,
+		Policy:   strings.SplitN(rule.ID, "/", 2)[0],
+		Rule:     rule.ID,
+		Severity: severity(rule.Severity),
+		Result:   result,
+		Message:  message,
+		Resources: []corev1.ObjectReference{
+			{
+				APIVersion: violation.Reference.ApiVersion,
+				Kind:       violation.Reference.Kind,
+				Namespace:  violation.Reference.Namespace,
+				Name:       violation.Reference.Name,
+			},
+		},
+		Properties: properties,
+		Scored:     true,
+	}
+}
+
+// severity maps the severity between 1 and 10 to the severities of policy reports.
+func severity(severity uint) string {
+	switch {
+	case severity >= 9:
+		return "critical"
+	case severity >= 7:
+		return "high"
+	case severity >= 4:
+		return "medium"
+	case severity >= 1:
+		return "low"
+	default:
+		return "info"
+	}
+}
+
+func resourceID(result Result) string {
+	if len(result.Resources) == 0 {
+		return ""
+	}
+	r := result.Resources[0]
+	return strings.Join([]string{r.APIVersion, r.Kind, r.Namespace, r.Name}, "/")
+}
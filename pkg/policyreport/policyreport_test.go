@@ -0,0 +1,76 @@
+package policyreport
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		PolicyReportResource:        "PolicyReportList",
+		ClusterPolicyReportResource: "ClusterPolicyReportList",
+	})
+	publisher := NewPublisher(client, "")
+
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "debug"}
+	node := graph.ObjectReference{ApiVersion: "v1", Kind: "Node", Name: "node-1"}
+	ruleResults := map[string]*check.RuleResult{
+		"WithoutController": {
+			Rule:       check.Rule{ID: "WithoutController", Severity: 8, Description: "Pods should not be created without a controller."},
+			Violations: []check.Violation{{Reference: pod, Object: pod}},
+		},
+		"BurstableInstanceType": {
+			Rule:       check.Rule{ID: "BurstableInstanceType", Severity: 3, Description: "Nodes should not use burstable instance types."},
+			Violations: []check.Violation{{Reference: node, Object: node}},
+		},
+	}
+	require.NoError(t, publisher.Publish(ctx, ruleResults))
+
+	report, err := client.Resource(PolicyReportResource).Namespace("foo").Get(ctx, Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	results, _, err := unstructured.NestedSlice(report.Object, "results")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	result := results[0].(map[string]interface{})
+	require.Equal(t, "WithoutController", result["policy"])
+	require.Equal(t, "high", result["severity"])
+	require.Equal(t, "fail", result["result"])
+	fail, _, err := unstructured.NestedInt64(report.Object, "summary", "fail")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), fail)
+
+	clusterReport, err := client.Resource(ClusterPolicyReportResource).Get(ctx, Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	results, _, err = unstructured.NestedSlice(clusterReport.Object, "results")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	// Unchanged reports are not updated
+	client.ClearActions()
+	require.NoError(t, publisher.Publish(ctx, ruleResults))
+	for _, action := range client.Actions() {
+		require.NotEqual(t, "update", action.GetVerb())
+	}
+
+	// Reports without results are removed
+	delete(ruleResults, "WithoutController")
+	require.NoError(t, publisher.Publish(ctx, ruleResults))
+	list, err := client.Resource(PolicyReportResource).Namespace("").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+	delete(ruleResults, "BurstableInstanceType")
+	require.NoError(t, publisher.Publish(ctx, ruleResults))
+	_, err = client.Resource(ClusterPolicyReportResource).Get(ctx, Name, metav1.GetOptions{})
+	require.Error(t, err)
+}
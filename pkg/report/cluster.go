@@ -0,0 +1,178 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// ClusterReport is the report of a single cluster.
+type ClusterReport struct {
+	// Context is the kubeconfig context used to scan the cluster.
+	Context string `json:"context"`
+	// Report is nil if the cluster could not be scanned.
+	Report *Report `json:"report,omitempty"`
+	// Error is the error which occurred when scanning the cluster or publishing the results.
+	Error string `json:"error,omitempty"`
+}
+
+// MultiClusterReport combines the reports of several clusters.
+type MultiClusterReport struct {
+	SchemaVersion string          `json:"schemaVersion"`
+	Clusters      []ClusterReport `json:"clusters"`
+	// Rules summarizes the violations of each rule across the clusters.
+	Rules []RuleSummary `json:"rules"`
+}
+
+// RuleSummary is a rule and the clusters with violations of it.
+type RuleSummary struct {
+	Rule     Rule                `json:"rule"`
+	Clusters []ClusterViolations `json:"clusters"`
+}
+
+// ClusterViolations is the number of violations of a rule in a cluster.
+type ClusterViolations struct {
+	Context    string `json:"context"`
+	Violations int    `json:"violations"`
+}
+
+// NewMultiCluster combines the cluster reports, sorted by context. Rule summaries
+// are sorted by severity and rule ID like the results of a single report.
+func NewMultiCluster(clusterReports []ClusterReport) MultiClusterReport {
+	clusters := append([]ClusterReport{}, clusterReports...)
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Context < clusters[j].Context
+	})
+	summaries := map[string]*RuleSummary{}
+	for _, cluster := range clusters {
+		if cluster.Report == nil {
+			continue
+		}
+		for _, result := range cluster.Report.Results {
+			if len(result.Violations) == 0 {
+				continue
+			}
+			summary, ok := summaries[result.Rule.ID]
+			if !ok {
+				summary = &RuleSummary{Rule: result.Rule}
+				summaries[result.Rule.ID] = summary
+			}
+			summary.Clusters = append(summary.Clusters, ClusterViolations{
+				Context:    cluster.Context,
+				Violations: len(result.Violations),
+			})
+		}
+	}
+	rules := []RuleSummary{}
+	for _, summary := range summaries {
+		rules = append(rules, *summary)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Rule.Severity != rules[j].Rule.Severity {
+			return rules[i].Rule.Severity > rules[j].Rule.Severity
+		}
+		return rules[i].Rule.ID < rules[j].Rule.ID
+	})
+	return MultiClusterReport{
+		SchemaVersion: SchemaVersion,
+		Clusters:      clusters,
+		Rules:         rules,
+	}
+}
+
+// Summary counts the violations in all clusters per severity.
+func (m MultiClusterReport) Summary() Summary {
+	summary := Summary{}
+	for _, cluster := range m.Clusters {
+		if cluster.Report == nil {
+			continue
+		}
+		for severity, count := range cluster.Report.Summary() {
+			summary[severity] += count
+		}
+	}
+	return summary
+}
+
+// Failing returns the failing rule IDs of every cluster, prefixed with the context.
+func (m MultiClusterReport) Failing(threshold uint, rulePatterns []string) []string {
+	failing := []string{}
+	for _, cluster := range m.Clusters {
+		if cluster.Report == nil {
+			continue
+		}
+		for _, id := range cluster.Report.Failing(threshold, rulePatterns) {
+			failing = append(failing, fmt.Sprintf("%s/%s", cluster.Context, id))
+		}
+	}
+	return failing
+}
+
+// Errors returns the errors of the clusters, prefixed with the context.
+func (m MultiClusterReport) Errors() []string {
+	errs := []string{}
+	for _, cluster := range m.Clusters {
+		if cluster.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", cluster.Context, cluster.Error))
+		}
+	}
+	return errs
+}
+
+// WriteMultiCluster writes the combined report in the given format, only table and json are supported.
+func WriteMultiCluster(w io.Writer, format Format, m MultiClusterReport) error {
+	switch format {
+	case FormatTable:
+		return WriteMultiClusterTable(w, m)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	default:
+		return fmt.Errorf("output format %s is not supported for multiple clusters", format)
+	}
+}
+
+// WriteMultiClusterTable writes the tables of each cluster followed by a table
+// with the clusters violating each rule. The error is written for clusters which could not be scanned.
+func WriteMultiClusterTable(w io.Writer, m MultiClusterReport) error {
+	for _, cluster := range m.Clusters {
+		fmt.Fprintf(w, "\n\n\n\nCluster: %s\n", cluster.Context)
+		if cluster.Error != "" {
+			fmt.Fprintf(w, "Error: %s\n", cluster.Error)
+		}
+		if cluster.Report == nil {
+			continue
+		}
+		err := WriteTable(w, *cluster.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\n\n\n\n")
+	summaryTable := tablewriter.NewWriter(w)
+	summaryTable.SetHeader([]string{"ID", "Severity", "Clusters", "Violations"})
+	summaryTable.SetAutoWrapText(false)
+	for _, summary := range m.Rules {
+		contexts := []string{}
+		violations := 0
+		for _, cluster := range summary.Clusters {
+			contexts = append(contexts, fmt.Sprintf("%s (%d)", cluster.Context, cluster.Violations))
+			violations += cluster.Violations
+		}
+		summaryTable.Append([]string{summary.Rule.ID, strconv.FormatUint(uint64(summary.Rule.Severity), 10), strings.Join(contexts, ", "), strconv.Itoa(violations)})
+	}
+	summaryTable.Render()
+	return nil
+}
+
+// SupportsMultiCluster returns true if the format can be used for multiple clusters.
+func SupportsMultiCluster(format Format) bool {
+	return format == FormatTable || format == FormatJSON
+}
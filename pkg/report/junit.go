@@ -0,0 +1,117 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Every rule is a test suite and
+// every object the rule was evaluated for is a test case, which fails if the
+// object violated the rule. Suppressed violations are skipped test cases, unless hidden.
+func WriteJUnit(w io.Writer, r Report) error {
+	suites := junitTestSuites{
+		Name:   "kube-checker",
+		Suites: []junitTestSuite{},
+	}
+	timestamp := ""
+	if !r.Metadata.Timestamp.IsZero() {
+		timestamp = r.Metadata.Timestamp.Format("2006-01-02T15:04:05")
+	}
+	for _, e := range r.evaluated {
+		suite := junitTestSuite{
+			Name:      e.Rule.ID,
+			Timestamp: timestamp,
+			Properties: []junitProperty{
+				{Name: "severity", Value: strconv.FormatUint(uint64(e.Rule.Severity), 10)},
+				{Name: "description", Value: e.Rule.Description},
+			},
+			TestCases: []junitTestCase{},
+		}
+		if e.Rule.Link != "" {
+			suite.Properties = append(suite.Properties, junitProperty{Name: "link", Value: e.Rule.Link})
+		}
+		for _, evaluation := range e.Evaluations {
+			testCase := junitTestCase{
+				Name:      evaluation.Object.ID(),
+				ClassName: e.Rule.ID,
+			}
+			if evaluation.Suppressed {
+				testCase.Skipped = &junitSkipped{Message: "suppressed by ignore annotation"}
+				suite.Skipped++
+			} else if evaluation.Violated {
+				message := e.Rule.Description
+				if evaluation.Message != "" {
+					message = fmt.Sprintf("%s %s", message, evaluation.Message)
+				}
+				testCase.Failure = &junitFailure{
+					Message: message,
+					Type:    fmt.Sprintf("severity %d", e.Rule.Severity),
+					Text:    message,
+				}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(suites)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
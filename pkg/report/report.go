@@ -0,0 +1,305 @@
+// Package report renders the results of a check in different output formats.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// SchemaVersion is the version of the report schema, it is changed
+// whenever a backwards incompatible change is made to the report.
+const SchemaVersion = "v1"
+
+type Format string
+
+const (
+	FormatTable   Format = "table"
+	FormatJSON    Format = "json"
+	FormatSARIF   Format = "sarif"
+	FormatJUnit   Format = "junit"
+	FormatUpgrade Format = "upgrade"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatTable, FormatJSON, FormatSARIF, FormatJUnit, FormatUpgrade:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s", s)
+	}
+}
+
+// Report contains the results of a check together with metadata about the scan.
+type Report struct {
+	SchemaVersion string   `json:"schemaVersion"`
+	Metadata      Metadata `json:"metadata"`
+	Results       []Result `json:"results"`
+	// Suppressed is the number of violations suppressed by ignore annotations.
+	Suppressed int `json:"suppressed"`
+	// Baseline is set if violations in a baseline have been removed from the results.
+	Baseline *BaselineComparison `json:"baseline,omitempty"`
+	// Rules are all the rules that were evaluated, including the ones without violations.
+	Rules []Rule `json:"-"`
+	// evaluated contains every rule with the objects it was evaluated for, sorted by rule ID.
+	evaluated []evaluatedRule
+}
+
+type evaluatedRule struct {
+	Rule        Rule
+	Evaluations []check.Evaluation
+}
+
+// Metadata describes the scan which produced the report.
+type Metadata struct {
+	// Cluster is the name of the cluster in the kubeconfig, empty when running in cluster or reading manifests.
+	Cluster string `json:"cluster,omitempty"`
+	// Manifests is the path to the manifests directory, empty when checking a cluster.
+	Manifests string `json:"manifests,omitempty"`
+	// Snapshot is the path to the snapshot, empty unless a snapshot is checked.
+	Snapshot string `json:"snapshot,omitempty"`
+	// Namespace is the namespace the scan was scoped to, empty when all namespaces are checked.
+	Namespace string `json:"namespace,omitempty"`
+	// Timestamp is the time the report was created.
+	Timestamp time.Time `json:"timestamp"`
+	// Version is the version of kube-checker.
+	Version string `json:"version"`
+	// DeprecationsVersion is a hash of the deprecation data used for the scan.
+	DeprecationsVersion string `json:"deprecationsVersion"`
+	// ServerVersion is the version of the cluster, empty when reading manifests.
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// TargetVersion is the Kubernetes version deprecations are classified against.
+	TargetVersion string `json:"targetVersion,omitempty"`
+}
+
+// BaselineComparison is the number of violations compared to a baseline.
+type BaselineComparison struct {
+	// New is the number of violations which are not in the baseline.
+	New int `json:"new"`
+	// Existing is the number of violations in the baseline which are still present.
+	Existing int `json:"existing"`
+	// Fixed is the number of violations in the baseline which are no longer present.
+	Fixed int `json:"fixed"`
+}
+
+// String returns a single line with the violation counts.
+func (b BaselineComparison) String() string {
+	return fmt.Sprintf("baseline: %d new, %d existing, %d fixed violations", b.New, b.Existing, b.Fixed)
+}
+
+// Result is a rule and all the violations of it.
+type Result struct {
+	Rule       Rule        `json:"rule"`
+	Violations []Violation `json:"violations"`
+	// Suppressed are the violations suppressed by ignore annotations, omitted unless suppressed violations are shown.
+	Suppressed []Violation `json:"suppressed,omitempty"`
+}
+
+// Rule describes a evaluated rule.
+type Rule struct {
+	ID          string `json:"id"`
+	Severity    uint   `json:"severity"`
+	Description string `json:"description"`
+	Link        string `json:"link,omitempty"`
+	// Deprecation is set for rules of deprecated api versions.
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+}
+
+// Deprecation describes a deprecated api version.
+type Deprecation struct {
+	Component     string `json:"component"`
+	ApiVersion    string `json:"apiVersion"`
+	Kind          string `json:"kind"`
+	DeprecatedIn  string `json:"deprecatedIn"`
+	RemovedIn     string `json:"removedIn,omitempty"`
+	NewApiVersion string `json:"newApiVersion"`
+	// Status is the status in the target version, one of Removed, Deprecated or Pending. Omitted without a target version.
+	Status string `json:"status,omitempty"`
+}
+
+// Violation is a single object which violates a rule.
+type Violation struct {
+	// Object is the object which was evaluated.
+	Object graph.ObjectReference `json:"object"`
+	// RootOwner is the top most owner of the object, which is the object itself if it has no owner.
+	RootOwner graph.ObjectReference `json:"rootOwner"`
+	// Source is the file the object was read from, omitted when checking a cluster.
+	Source  *graph.Source `json:"source,omitempty"`
+	Message string        `json:"message,omitempty"`
+	// Suppression is set for suppressed violations.
+	Suppression *Suppression `json:"suppression,omitempty"`
+}
+
+// Suppression describes why a violation is suppressed.
+type Suppression struct {
+	// Object is the object with the ignore annotation.
+	Object graph.ObjectReference `json:"object"`
+	Reason string                `json:"reason,omitempty"`
+}
+
+// New creates a report from the checker rules and the rule results. Results are
+// sorted by severity and rule ID, violations by the object reference.
+func New(metadata Metadata, rules []check.Rule, ruleResults map[string]*check.RuleResult) Report {
+	reportRules := []Rule{}
+	for _, rule := range rules {
+		reportRules = append(reportRules, newRule(rule))
+	}
+	evaluated := []evaluatedRule{}
+	for _, rule := range reportRules {
+		if _, ok := ruleResults[rule.ID]; ok {
+			continue
+		}
+		evaluated = append(evaluated, evaluatedRule{Rule: rule})
+	}
+	results := []Result{}
+	suppressed := 0
+	for _, ruleResult := range ruleResults {
+		evaluated = append(evaluated, evaluatedRule{
+			Rule:        newRule(ruleResult.Rule),
+			Evaluations: ruleResult.Evaluations,
+		})
+		if len(ruleResult.Violations) == 0 && len(ruleResult.Suppressed) == 0 {
+			continue
+		}
+		result := Result{
+			Rule:       newRule(ruleResult.Rule),
+			Violations: newViolations(ruleResult.Violations),
+		}
+		if len(ruleResult.Suppressed) > 0 {
+			result.Suppressed = newViolations(ruleResult.Suppressed)
+			suppressed += len(result.Suppressed)
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rule.Severity != results[j].Rule.Severity {
+			return results[i].Rule.Severity > results[j].Rule.Severity
+		}
+		return results[i].Rule.ID < results[j].Rule.ID
+	})
+	sort.Slice(evaluated, func(i, j int) bool {
+		return evaluated[i].Rule.ID < evaluated[j].Rule.ID
+	})
+	return Report{
+		SchemaVersion: SchemaVersion,
+		Metadata:      metadata,
+		Results:       results,
+		Suppressed:    suppressed,
+		Rules:         reportRules,
+		evaluated:     evaluated,
+	}
+}
+
+// HideSuppressed removes the suppressed violations from the results and evaluations, only keeping the count.
+func (r *Report) HideSuppressed() {
+	results := []Result{}
+	for _, result := range r.Results {
+		result.Suppressed = nil
+		if len(result.Violations) == 0 {
+			continue
+		}
+		results = append(results, result)
+	}
+	r.Results = results
+	evaluated := []evaluatedRule{}
+	for _, e := range r.evaluated {
+		evaluations := []check.Evaluation{}
+		for _, evaluation := range e.Evaluations {
+			if evaluation.Suppressed {
+				continue
+			}
+			evaluations = append(evaluations, evaluation)
+		}
+		evaluated = append(evaluated, evaluatedRule{Rule: e.Rule, Evaluations: evaluations})
+	}
+	r.evaluated = evaluated
+}
+
+// ClearEvaluations marks the violated evaluations for which clear returns true as passed,
+// so that formats listing every evaluation no longer report them as violations.
+func (r *Report) ClearEvaluations(clear func(ruleID string, rootOwner graph.ObjectReference, message string) bool) {
+	evaluated := []evaluatedRule{}
+	for _, e := range r.evaluated {
+		evaluations := []check.Evaluation{}
+		for _, evaluation := range e.Evaluations {
+			if evaluation.Violated && !evaluation.Suppressed && clear(e.Rule.ID, evaluation.RootOwner, evaluation.Message) {
+				evaluation.Violated = false
+				evaluation.Message = ""
+			}
+			evaluations = append(evaluations, evaluation)
+		}
+		evaluated = append(evaluated, evaluatedRule{Rule: e.Rule, Evaluations: evaluations})
+	}
+	r.evaluated = evaluated
+}
+
+// newViolations converts the violations sorted by the object reference.
+func newViolations(violations []check.Violation) []Violation {
+	result := []Violation{}
+	for _, v := range violations {
+		violation := Violation{
+			Object:    v.Object,
+			RootOwner: v.Reference,
+			Message:   v.Message,
+		}
+		if v.Source.Path != "" {
+			source := v.Source
+			violation.Source = &source
+		}
+		if v.Suppression != nil {
+			violation.Suppression = &Suppression{
+				Object: v.Suppression.Object,
+				Reason: v.Suppression.Reason,
+			}
+		}
+		result = append(result, violation)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Object.ID() < result[j].Object.ID()
+	})
+	return result
+}
+
+func newRule(rule check.Rule) Rule {
+	r := Rule{
+		ID:          rule.ID,
+		Severity:    rule.Severity,
+		Description: rule.Description,
+		Link:        rule.Link,
+	}
+	if rule.Deprecation != nil {
+		r.Deprecation = &Deprecation{
+			Component:     rule.Deprecation.Component,
+			ApiVersion:    rule.Deprecation.ApiVersion,
+			Kind:          rule.Deprecation.Kind,
+			DeprecatedIn:  rule.Deprecation.DeprecatedIn,
+			RemovedIn:     rule.Deprecation.RemovedIn,
+			NewApiVersion: rule.Deprecation.NewApiVersion,
+			Status:        string(rule.DeprecationStatus),
+		}
+	}
+	return r
+}
+
+// Write writes the report in the given format.
+func Write(w io.Writer, format Format, r Report) error {
+	switch format {
+	case FormatTable:
+		return WriteTable(w, r)
+	case FormatJSON:
+		return WriteJSON(w, r)
+	case FormatSARIF:
+		return WriteSARIF(w, r)
+	case FormatJUnit:
+		return WriteJUnit(w, r)
+	case FormatUpgrade:
+		return WriteUpgrade(w, r)
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
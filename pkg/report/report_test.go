@@ -0,0 +1,220 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+func TestNewSortsResults(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "bar-abc"}
+	deployment := graph.ObjectReference{ApiVersion: "apps/v1", Kind: "Deployment", Namespace: "foo", Name: "bar"}
+	ruleResults := map[string]*check.RuleResult{
+		"Low": {
+			Rule: check.Rule{ID: "Low", Severity: 1},
+			Violations: []check.Violation{
+				{Reference: pod, Object: pod},
+			},
+		},
+		"Empty": {
+			Rule: check.Rule{ID: "Empty", Severity: 5},
+		},
+		"High": {
+			Rule: check.Rule{ID: "High", Severity: 8, Description: "foo", Link: "https://example.com"},
+			Violations: []check.Violation{
+				{Reference: deployment, Object: pod, Message: "container bar"},
+			},
+		},
+	}
+	r := New(Metadata{Timestamp: time.Unix(0, 0).UTC(), Version: "dev"}, nil, ruleResults)
+	require.Equal(t, SchemaVersion, r.SchemaVersion)
+	require.Len(t, r.Results, 2)
+	require.Equal(t, "High", r.Results[0].Rule.ID)
+	require.Equal(t, "Low", r.Results[1].Rule.ID)
+	require.Equal(t, pod, r.Results[0].Violations[0].Object)
+	require.Equal(t, deployment, r.Results[0].Violations[0].RootOwner)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatJSON, r))
+	decoded := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	results := decoded["results"].([]interface{})
+	violation := results[0].(map[string]interface{})["violations"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, "Deployment", violation["rootOwner"].(map[string]interface{})["kind"])
+	require.Equal(t, "container bar", violation["message"])
+}
+
+func TestWriteSARIF(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "bar"}
+	rules := []check.Rule{
+		{ID: "WithoutController", Severity: 8, Description: "Pods should not be created without a controller.", Link: "https://example.com"},
+		{ID: "ImagePullPolicyAlways", Severity: 1, Description: "Pod is using image pull policy always."},
+	}
+	ruleResults := map[string]*check.RuleResult{
+		"WithoutController": {
+			Rule: rules[0],
+			Violations: []check.Violation{
+				{Reference: pod, Object: pod, Source: graph.Source{Path: "deploy/pod.yaml", Line: 3}},
+			},
+		},
+	}
+	r := New(Metadata{Version: "dev"}, rules, ruleResults)
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatSARIF, r))
+
+	log := sarifLog{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	descriptors := log.Runs[0].Tool.Driver.Rules
+	require.Len(t, descriptors, 2)
+	require.Equal(t, "https://example.com", descriptors[0].HelpURI)
+	require.Equal(t, "error", descriptors[0].DefaultConfiguration.Level)
+	require.Equal(t, "note", descriptors[1].DefaultConfiguration.Level)
+	require.Len(t, log.Runs[0].Results, 1)
+	result := log.Runs[0].Results[0]
+	require.Equal(t, "WithoutController", result.RuleID)
+	require.Equal(t, 0, result.RuleIndex)
+	require.Equal(t, "deploy/pod.yaml", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	require.Equal(t, 3, result.Locations[0].PhysicalLocation.Region.StartLine)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "bar"}
+	other := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "baz"}
+	rules := []check.Rule{
+		{ID: "WithoutController", Severity: 8, Description: "Pods should not be created without a controller."},
+		{ID: "OnAllNodes", Severity: 5, Description: "Daemonset is not running on all nodes."},
+	}
+	ruleResults := map[string]*check.RuleResult{
+		"WithoutController": {
+			Rule:       rules[0],
+			Violations: []check.Violation{{Reference: pod, Object: pod}},
+			Evaluations: []check.Evaluation{
+				{Object: pod, Violated: true},
+				{Object: other},
+			},
+		},
+	}
+	r := New(Metadata{}, rules, ruleResults)
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatJUnit, r))
+
+	suites := junitTestSuites{}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 2, suites.Tests)
+	require.Equal(t, 1, suites.Failures)
+	require.Len(t, suites.Suites, 2)
+	require.Equal(t, "OnAllNodes", suites.Suites[0].Name)
+	require.Empty(t, suites.Suites[0].TestCases)
+	require.Equal(t, "WithoutController", suites.Suites[1].Name)
+	require.Equal(t, "v1/Pod/foo/bar", suites.Suites[1].TestCases[0].Name)
+	require.NotNil(t, suites.Suites[1].TestCases[0].Failure)
+	require.Nil(t, suites.Suites[1].TestCases[1].Failure)
+
+	// Suppressed violations are skipped unless hidden
+	ruleResults["WithoutController"].Suppressed = []check.Violation{{Reference: other, Object: other}}
+	ruleResults["WithoutController"].Evaluations[1] = check.Evaluation{Object: other, Violated: true, Suppressed: true}
+	r = New(Metadata{}, rules, ruleResults)
+	buf.Reset()
+	require.NoError(t, Write(buf, FormatJUnit, r))
+	suites = junitTestSuites{}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 1, suites.Skipped)
+	r.HideSuppressed()
+	buf.Reset()
+	require.NoError(t, Write(buf, FormatJUnit, r))
+	suites = junitTestSuites{}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 0, suites.Skipped)
+	require.Equal(t, 1, suites.Tests)
+}
+
+func TestFailing(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "bar"}
+	ruleResults := map[string]*check.RuleResult{
+		"APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget": {
+			Rule:       check.Rule{ID: "APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", Severity: 10},
+			Violations: []check.Violation{{Reference: pod, Object: pod}},
+		},
+		"NoTLS": {
+			Rule:       check.Rule{ID: "NoTLS", Severity: 6},
+			Violations: []check.Violation{{Reference: pod, Object: pod}, {Reference: pod, Object: pod}},
+		},
+		"OnAllNodes": {
+			Rule: check.Rule{ID: "OnAllNodes", Severity: 8},
+		},
+	}
+	r := New(Metadata{}, nil, ruleResults)
+	require.Equal(t, Summary{10: 1, 6: 2}, r.Summary())
+	require.Equal(t, "found 3 violations (severity 10: 1, severity 6: 2)", r.Summary().String())
+
+	require.Empty(t, r.Failing(0, nil))
+	require.Equal(t, []string{"APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget"}, r.Failing(7, nil))
+	require.Equal(t, []string{"APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget", "NoTLS"}, r.Failing(6, nil))
+	require.Equal(t, []string{"NoTLS"}, r.Failing(0, []string{"No*"}))
+	require.Empty(t, r.Failing(0, []string{"OnAllNodes"}))
+	require.Equal(t, []string{"APIVersionDeprecated/policy/v1beta1/PodDisruptionBudget"}, r.Failing(0, []string{"APIVersionDeprecated/*"}))
+
+	require.NoError(t, ValidateRulePatterns([]string{"APIVersionDeprecated/*/*/*"}))
+	require.Error(t, ValidateRulePatterns([]string{"["}))
+}
+
+func TestNewMultiCluster(t *testing.T) {
+	pod := graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "bar"}
+	pdb := graph.ObjectReference{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Namespace: "foo", Name: "bar"}
+	removed := check.Rule{ID: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", Severity: 10}
+	noTLS := check.Rule{ID: "NoTLS", Severity: 6}
+	dev := New(Metadata{Cluster: "dev"}, nil, map[string]*check.RuleResult{
+		removed.ID: {Rule: removed, Violations: []check.Violation{{Reference: pdb, Object: pdb}}},
+		noTLS.ID:   {Rule: noTLS, Violations: []check.Violation{{Reference: pod, Object: pod}}},
+	})
+	prod := New(Metadata{Cluster: "prod"}, nil, map[string]*check.RuleResult{
+		removed.ID: {Rule: removed, Violations: []check.Violation{{Reference: pdb, Object: pdb}, {Reference: pdb, Object: pdb}}},
+	})
+	m := NewMultiCluster([]ClusterReport{{Context: "prod", Report: &prod}, {Context: "test", Error: "connection refused"}, {Context: "dev", Report: &dev}})
+	require.Equal(t, "dev", m.Clusters[0].Context)
+	require.Equal(t, "prod", m.Clusters[1].Context)
+	require.Equal(t, []string{"test: connection refused"}, m.Errors())
+	require.Len(t, m.Rules, 2)
+	require.Equal(t, removed.ID, m.Rules[0].Rule.ID)
+	require.Equal(t, []ClusterViolations{{Context: "dev", Violations: 1}, {Context: "prod", Violations: 2}}, m.Rules[0].Clusters)
+	require.Equal(t, []ClusterViolations{{Context: "dev", Violations: 1}}, m.Rules[1].Clusters)
+	require.Equal(t, Summary{10: 3, 6: 1}, m.Summary())
+	require.Equal(t, []string{"dev/" + removed.ID, "prod/" + removed.ID}, m.Failing(7, nil))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteMultiCluster(buf, FormatTable, m))
+	require.Contains(t, buf.String(), "dev (1), prod (2)")
+	require.Contains(t, buf.String(), "Error: connection refused")
+	require.Error(t, WriteMultiCluster(buf, FormatSARIF, m))
+}
+
+func TestWriteUpgrade(t *testing.T) {
+	pdb := graph.ObjectReference{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Namespace: "foo", Name: "bar"}
+	r := Report{Results: []Result{{
+		Rule:       Rule{ID: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", Deprecation: &Deprecation{ApiVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Status: "Removed"}},
+		Violations: []Violation{{Object: pdb, RootOwner: pdb}},
+	}}}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, FormatUpgrade, r))
+	require.Contains(t, buf.String(), "Upgrade ready: unknown, no target version is set")
+
+	r.Metadata.TargetVersion = "v1.25.0"
+	buf.Reset()
+	require.NoError(t, Write(buf, FormatUpgrade, r))
+	require.Contains(t, buf.String(), "Upgrade ready: no, 1 objects use removed api versions")
+
+	r.Results = nil
+	buf.Reset()
+	require.NoError(t, Write(buf, FormatUpgrade, r))
+	require.Contains(t, buf.String(), "Upgrade ready: yes")
+}
@@ -0,0 +1,215 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri"`
+	Version        string                     `json:"version"`
+	Rules          []sarifReportingDescriptor `json:"rules"`
+}
+
+type sarifReportingDescriptor struct {
+	ID                   string              `json:"id"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	HelpURI              string              `json:"helpUri,omitempty"`
+	DefaultConfiguration sarifConfiguration  `json:"defaultConfiguration"`
+	Properties           sarifRuleProperties `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProperties struct {
+	Severity uint `json:"severity"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations,omitempty"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps a rule severity to a SARIF level.
+func sarifLevel(severity uint) string {
+	switch {
+	case severity >= 7:
+		return "error"
+	case severity >= 4:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// sarifURI returns the artifact URI for a file path, relative paths are kept
+// relative so that they resolve against the repository root.
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		return "file://" + filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(path)
+}
+
+// WriteSARIF writes the report as a SARIF 2.1.0 log with a single run.
+func WriteSARIF(w io.Writer, r Report) error {
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{newSARIFRun(r)},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func newSARIFRun(r Report) sarifRun {
+	descriptors := []sarifReportingDescriptor{}
+	ruleIndex := map[string]int{}
+	addRule := func(rule Rule) {
+		if _, ok := ruleIndex[rule.ID]; ok {
+			return
+		}
+		ruleIndex[rule.ID] = len(descriptors)
+		descriptors = append(descriptors, sarifReportingDescriptor{
+			ID:               rule.ID,
+			ShortDescription: sarifMessage{Text: rule.Description},
+			HelpURI:          rule.Link,
+			DefaultConfiguration: sarifConfiguration{
+				Level: sarifLevel(rule.Severity),
+			},
+			Properties: sarifRuleProperties{
+				Severity: rule.Severity,
+			},
+		})
+	}
+	for _, rule := range r.Rules {
+		addRule(rule)
+	}
+	// Deprecation rules are only known once they have been violated
+	for _, result := range r.Results {
+		addRule(result.Rule)
+	}
+
+	results := []sarifResult{}
+	for _, result := range r.Results {
+		violations := append(append([]Violation{}, result.Violations...), result.Suppressed...)
+		for _, v := range violations {
+			text := fmt.Sprintf("%s %s: %s", v.Object.Kind, objectName(v.Object.Namespace, v.Object.Name), result.Rule.Description)
+			if v.Message != "" {
+				text = fmt.Sprintf("%s (%s)", text, v.Message)
+			}
+			location := sarifLocation{
+				LogicalLocations: []sarifLogicalLocation{
+					{
+						FullyQualifiedName: v.Object.ID(),
+						Kind:               "object",
+					},
+				},
+			}
+			if v.Source != nil {
+				location.PhysicalLocation = &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: sarifURI(v.Source.Path)},
+				}
+				if v.Source.Line > 0 {
+					location.PhysicalLocation.Region = &sarifRegion{StartLine: v.Source.Line}
+				}
+			}
+			sarifRes := sarifResult{
+				RuleID:    result.Rule.ID,
+				RuleIndex: ruleIndex[result.Rule.ID],
+				Level:     sarifLevel(result.Rule.Severity),
+				Message:   sarifMessage{Text: text},
+				Locations: []sarifLocation{location},
+			}
+			if v.Suppression != nil {
+				sarifRes.Suppressions = []sarifSuppression{
+					{
+						Kind:          "inSource",
+						Justification: v.Suppression.Reason,
+					},
+				}
+			}
+			results = append(results, sarifRes)
+		}
+	}
+
+	return sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "kube-checker",
+				InformationURI: "https://github.com/XenitAB/kube-checker",
+				Version:        r.Metadata.Version,
+				Rules:          descriptors,
+			},
+		},
+		Results: results,
+	}
+}
+
+// objectName returns the namespaced name of an object.
+func objectName(namespace, name string) string {
+	return strings.TrimPrefix(namespace+"/"+name, "/")
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+)
+
+// Summary is the number of violations for each severity.
+type Summary map[uint]int
+
+// Summary counts the violations in the report per severity.
+func (r Report) Summary() Summary {
+	summary := Summary{}
+	for _, result := range r.Results {
+		summary[result.Rule.Severity] += len(result.Violations)
+	}
+	return summary
+}
+
+// Total returns the total number of violations.
+func (s Summary) Total() int {
+	total := 0
+	for _, count := range s {
+		total += count
+	}
+	return total
+}
+
+// String returns a single line with the violation count for each severity, highest severity first.
+func (s Summary) String() string {
+	severities := []uint{}
+	for severity := range s {
+		severities = append(severities, severity)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		return severities[i] > severities[j]
+	})
+	counts := []string{}
+	for _, severity := range severities {
+		counts = append(counts, fmt.Sprintf("severity %d: %d", severity, s[severity]))
+	}
+	if len(counts) == 0 {
+		return "found 0 violations"
+	}
+	return fmt.Sprintf("found %d violations (%s)", s.Total(), strings.Join(counts, ", "))
+}
+
+// ValidateRulePatterns returns an error if any of the rule ID patterns is malformed.
+func ValidateRulePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := check.MatchRuleID(pattern, ""); err != nil {
+			return fmt.Errorf("invalid rule pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Failing returns the IDs of the rules with violations that either have a
+// severity equal to or above the threshold or match any of the rule ID patterns.
+// A threshold of zero disables the severity check.
+func (r Report) Failing(threshold uint, rulePatterns []string) []string {
+	ids := []string{}
+	for _, result := range r.Results {
+		if len(result.Violations) == 0 {
+			continue
+		}
+		if threshold > 0 && result.Rule.Severity >= threshold {
+			ids = append(ids, result.Rule.ID)
+			continue
+		}
+		for _, pattern := range rulePatterns {
+			if ok, _ := check.MatchRuleID(pattern, result.Rule.ID); ok {
+				ids = append(ids, result.Rule.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
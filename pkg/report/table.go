@@ -0,0 +1,42 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// WriteTable writes a table for each rule followed by a table of its violations,
+// and a table of its suppressed violations if there are any.
+func WriteTable(w io.Writer, r Report) error {
+	checkTable := tablewriter.NewWriter(w)
+	checkTable.SetHeader([]string{"ID", "Severity", "Description"})
+	violationTable := tablewriter.NewWriter(w)
+	violationTable.SetHeader([]string{"Api Version", "Kind", "Namespace", "Name", "Message"})
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "\n\n\n\n")
+
+		checkTable.ClearRows()
+		checkTable.Append([]string{result.Rule.ID, strconv.FormatUint(uint64(result.Rule.Severity), 10), result.Rule.Description})
+		checkTable.Render()
+
+		violationTable.ClearRows()
+		for _, v := range result.Violations {
+			violationTable.Append([]string{v.RootOwner.ApiVersion, v.RootOwner.Kind, v.RootOwner.Namespace, v.RootOwner.Name, v.Message})
+		}
+		violationTable.Render()
+
+		if len(result.Suppressed) == 0 {
+			continue
+		}
+		suppressedTable := tablewriter.NewWriter(w)
+		suppressedTable.SetHeader([]string{"Api Version", "Kind", "Namespace", "Name", "Suppressed By", "Reason"})
+		for _, v := range result.Suppressed {
+			suppressedTable.Append([]string{v.RootOwner.ApiVersion, v.RootOwner.Kind, v.RootOwner.Namespace, v.RootOwner.Name, v.Suppression.Object.ID(), v.Suppression.Reason})
+		}
+		suppressedTable.Render()
+	}
+	return nil
+}
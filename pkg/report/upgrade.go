@@ -0,0 +1,97 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+)
+
+type upgradeSection struct {
+	status string
+	title  string
+}
+
+// upgradeSections are the deprecation statuses in the order they are written.
+var upgradeSections = []upgradeSection{
+	{
+		status: string(check.DeprecationStatusRemoved),
+		title:  "Blocking: api versions removed in or before the target version",
+	},
+	{
+		status: string(check.DeprecationStatusDeprecated),
+		title:  "Deprecated: api versions still served in the target version",
+	},
+	{
+		status: string(check.DeprecationStatusPending),
+		title:  "Not yet relevant: api versions deprecated after the target version",
+	},
+}
+
+// WriteUpgrade writes an upgrade readiness report, which lists every object using
+// a deprecated api version grouped by the deprecation status in the target version.
+// Deprecations without a status, such as ones for components other than Kubernetes,
+// are listed as deprecated.
+func WriteUpgrade(w io.Writer, r Report) error {
+	serverVersion := r.Metadata.ServerVersion
+	if serverVersion == "" {
+		serverVersion = "unknown"
+	}
+	targetVersion := r.Metadata.TargetVersion
+	if targetVersion == "" {
+		targetVersion = "not set"
+	}
+	fmt.Fprintf(w, "Server version: %s\n", serverVersion)
+	fmt.Fprintf(w, "Target version: %s\n", targetVersion)
+
+	rows := map[string][][]string{}
+	for _, result := range r.Results {
+		d := result.Rule.Deprecation
+		if d == nil {
+			continue
+		}
+		status := d.Status
+		if status == "" {
+			status = string(check.DeprecationStatusDeprecated)
+		}
+		for _, v := range result.Violations {
+			rows[status] = append(rows[status], []string{d.ApiVersion, v.Object.Kind, v.Object.Namespace, v.Object.Name, d.NewApiVersion, d.DeprecatedIn, d.RemovedIn})
+		}
+	}
+
+	for _, section := range upgradeSections {
+		sectionRows := rows[section.status]
+		sort.Slice(sectionRows, func(i, j int) bool {
+			for k := range sectionRows[i] {
+				if sectionRows[i][k] != sectionRows[j][k] {
+					return sectionRows[i][k] < sectionRows[j][k]
+				}
+			}
+			return false
+		})
+		fmt.Fprintf(w, "\n%s (%d)\n", section.title, len(sectionRows))
+		if len(sectionRows) == 0 {
+			continue
+		}
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"Api Version", "Kind", "Namespace", "Name", "Replacement", "Deprecated In", "Removed In"})
+		table.AppendBulk(sectionRows)
+		table.Render()
+	}
+
+	// Without a target version the deprecations are not classified, so readiness is unknown
+	if r.Metadata.TargetVersion == "" {
+		fmt.Fprintf(w, "\nUpgrade ready: unknown, no target version is set\n")
+		return nil
+	}
+	blocking := len(rows[string(check.DeprecationStatusRemoved)])
+	if blocking > 0 {
+		fmt.Fprintf(w, "\nUpgrade ready: no, %d objects use removed api versions\n", blocking)
+		return nil
+	}
+	fmt.Fprintf(w, "\nUpgrade ready: yes\n")
+	return nil
+}
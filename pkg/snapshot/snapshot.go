@@ -0,0 +1,295 @@
+// Package snapshot saves the objects of a graph to a compressed archive, so that the graph can be rebuilt without cluster access.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// Version is the version of the snapshot format.
+const Version = "v1"
+
+const (
+	metadataFile = "metadata.json"
+	objectsFile  = "objects.json"
+	// lastAppliedAnnotation contains the full object as applied by kubectl, including the data of secrets.
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+)
+
+// Snapshot is the state of a cluster.
+type Snapshot struct {
+	Metadata Metadata
+	Objects  []unstructured.Unstructured
+}
+
+// Metadata describes the cluster and how the snapshot was created.
+type Metadata struct {
+	Version string `json:"version"`
+	// Timestamp is the time the snapshot was created.
+	Timestamp time.Time `json:"timestamp"`
+	// ServerVersion is the version of the cluster.
+	ServerVersion string `json:"serverVersion,omitempty"`
+	// Namespace is the namespace the snapshot was scoped to, empty when all namespaces are included.
+	Namespace string `json:"namespace,omitempty"`
+	// Resources are the resources discovered in the cluster.
+	Resources []Resource `json:"resources"`
+	// Redacted is true if the data of secrets has been removed.
+	Redacted bool `json:"redacted"`
+}
+
+// Resource is a resource discovered in the cluster.
+type Resource struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// objectList is written as a List so that the objects can be read by kubectl.
+type objectList struct {
+	ApiVersion string                   `json:"apiVersion"`
+	Kind       string                   `json:"kind"`
+	Items      []map[string]interface{} `json:"items"`
+}
+
+// New creates a snapshot of the objects in the graph. Redacting removes the data of all secrets,
+// which also removes the Helm releases as they are stored in secrets.
+func New(g *graph.Graph, namespace string, redact bool) *Snapshot {
+	resources := []Resource{}
+	for _, gvr := range g.Resources() {
+		resources = append(resources, Resource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource})
+	}
+	objects := []unstructured.Unstructured{}
+	for _, u := range g.Objects() {
+		if redact && graph.IsHelmReleaseSecret(u) {
+			continue
+		}
+		u = *u.DeepCopy()
+		if redact && u.GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Secret"}) {
+			redactSecret(u)
+		}
+		objects = append(objects, u)
+	}
+	return &Snapshot{
+		Metadata: Metadata{
+			Version:       Version,
+			Timestamp:     time.Now().UTC(),
+			ServerVersion: g.ServerVersion(),
+			Namespace:     namespace,
+			Resources:     resources,
+			Redacted:      redact,
+		},
+		Objects: objects,
+	}
+}
+
+// redactSecret replaces the values of the secret with empty strings, keeping the keys.
+func redactSecret(u unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		data, ok, _ := unstructured.NestedMap(u.Object, field)
+		if !ok {
+			continue
+		}
+		for key := range data {
+			data[key] = ""
+		}
+		unstructured.SetNestedMap(u.Object, data, field)
+	}
+	annotations := u.GetAnnotations()
+	if _, ok := annotations[lastAppliedAnnotation]; ok {
+		delete(annotations, lastAppliedAnnotation)
+		u.SetAnnotations(annotations)
+	}
+}
+
+// Populate fills the graph with the objects in the snapshot.
+func (s *Snapshot) Populate(ctx context.Context, g *graph.Graph) error {
+	return g.PopulateFromObjects(ctx, s.Metadata.ServerVersion, s.Objects)
+}
+
+// Write writes the snapshot as a gzipped tar archive with the metadata and the objects as JSON files.
+func (s *Snapshot) Write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create snapshot file: %w", err)
+	}
+	defer f.Close()
+	err = s.write(f)
+	if err != nil {
+		return fmt.Errorf("could not write snapshot file %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func (s *Snapshot) write(w io.Writer) error {
+	metadata, err := json.MarshalIndent(s.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	list := objectList{ApiVersion: "v1", Kind: "List", Items: []map[string]interface{}{}}
+	for _, u := range s.Objects {
+		list.Items = append(list.Items, u.Object)
+	}
+	objects, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{name: metadataFile, data: metadata},
+		{name: objectsFile, data: objects},
+	} {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0644,
+			Size:    int64(len(file.data)),
+			ModTime: s.Metadata.Timestamp,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(file.data)
+		if err != nil {
+			return err
+		}
+	}
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// Load reads a snapshot file.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot file: %w", err)
+	}
+	defer f.Close()
+	s, err := read(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// LoadMetadata reads the metadata of a snapshot file without decoding the objects.
+func LoadMetadata(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("could not read snapshot file: %w", err)
+	}
+	defer f.Close()
+	metadata, err := readMetadata(f)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("could not read snapshot file %s: %w", path, err)
+	}
+	return metadata, nil
+}
+
+func readMetadata(r io.Reader) (Metadata, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return Metadata{}, fmt.Errorf("archive has to contain %s", metadataFile)
+		}
+		if err != nil {
+			return Metadata{}, err
+		}
+		if header.Name != metadataFile {
+			continue
+		}
+		metadata := Metadata{}
+		err = json.NewDecoder(tr).Decode(&metadata)
+		if err != nil {
+			return Metadata{}, fmt.Errorf("could not decode %s: %w", header.Name, err)
+		}
+		return metadata, validateVersion(metadata)
+	}
+}
+
+func validateVersion(metadata Metadata) error {
+	if metadata.Version != Version {
+		return fmt.Errorf("unsupported snapshot version %q, expected %q", metadata.Version, Version)
+	}
+	return nil
+}
+
+func read(r io.Reader) (*Snapshot, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	var metadata *Metadata
+	var list *objectList
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch header.Name {
+		case metadataFile:
+			metadata = &Metadata{}
+			err = json.NewDecoder(tr).Decode(metadata)
+		case objectsFile:
+			list, err = decodeObjects(tr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not decode %s: %w", header.Name, err)
+		}
+	}
+	if metadata == nil || list == nil {
+		return nil, fmt.Errorf("archive has to contain %s and %s", metadataFile, objectsFile)
+	}
+	if err := validateVersion(*metadata); err != nil {
+		return nil, err
+	}
+	objects := []unstructured.Unstructured{}
+	for _, item := range list.Items {
+		objects = append(objects, unstructured.Unstructured{Object: item})
+	}
+	return &Snapshot{Metadata: *metadata, Objects: objects}, nil
+}
+
+// decodeObjects decodes integers as int64 like the objects read from the cluster, instead of float64.
+func decodeObjects(r io.Reader) (*objectList, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	list := &objectList{}
+	err = utiljson.Unmarshal(data, list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
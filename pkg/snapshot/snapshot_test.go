@@ -0,0 +1,100 @@
+package snapshot
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+func TestWriteAndLoad(t *testing.T) {
+	g := graph.NewGraph()
+	pod := unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetNamespace("foo")
+	pod.SetName("bar")
+	pod.SetUID("1")
+	secret := unstructured.Unstructured{Object: map[string]interface{}{
+		"data": map[string]interface{}{"password": "c2VjcmV0"},
+	}}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace("foo")
+	secret.SetName("credentials")
+	secret.SetUID("2")
+	secret.SetAnnotations(map[string]string{lastAppliedAnnotation: `{"data":{"password":"c2VjcmV0"}}`})
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "v1.22.0", []unstructured.Unstructured{pod, secret}))
+
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	require.NoError(t, New(g, "foo", false).Write(path))
+	s, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, Version, s.Metadata.Version)
+	require.Equal(t, "v1.22.0", s.Metadata.ServerVersion)
+	require.Equal(t, "foo", s.Metadata.Namespace)
+	require.False(t, s.Metadata.Redacted)
+	require.Len(t, s.Objects, 2)
+	require.Equal(t, "bar", s.Objects[0].GetName())
+	metadata, err := LoadMetadata(path)
+	require.NoError(t, err)
+	require.Equal(t, s.Metadata.Namespace, metadata.Namespace)
+	require.Equal(t, s.Metadata.ServerVersion, metadata.ServerVersion)
+	password, _, _ := unstructured.NestedString(s.Objects[1].Object, "data", "password")
+	require.Equal(t, "c2VjcmV0", password)
+
+	loaded := graph.NewGraph()
+	require.NoError(t, s.Populate(context.Background(), loaded))
+	require.Equal(t, "v1.22.0", loaded.ServerVersion())
+	require.NotNil(t, loaded.NodeByReferenceID("v1/Pod/foo/bar"))
+
+	require.NoError(t, New(g, "foo", true).Write(path))
+	s, err = Load(path)
+	require.NoError(t, err)
+	require.True(t, s.Metadata.Redacted)
+	password, _, _ = unstructured.NestedString(s.Objects[1].Object, "data", "password")
+	require.Empty(t, password)
+	require.NotContains(t, s.Objects[1].GetAnnotations(), lastAppliedAnnotation)
+	// The graph is not modified by redacting
+	password, _, _ = unstructured.NestedString(g.NodeByReferenceID("v1/Secret/foo/credentials").Unstructured.Object, "data", "password")
+	require.Equal(t, "c2VjcmV0", password)
+}
+
+func TestLoadPreservesIntegers(t *testing.T) {
+	g := graph.NewGraph()
+	deployment := unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":  "app",
+							"ports": []interface{}{map[string]interface{}{"containerPort": int64(8080)}},
+						},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"ratio": 0.5},
+	}}
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetNamespace("foo")
+	deployment.SetName("bar")
+	deployment.SetUID("1")
+	deployment.SetGeneration(2)
+	require.NoError(t, g.PopulateFromObjects(context.Background(), "v1.22.0", []unstructured.Unstructured{deployment}))
+
+	path := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	require.NoError(t, New(g, "", false).Write(path))
+	s, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, s.Objects, 1)
+	require.True(t, reflect.DeepEqual(deployment.Object, s.Objects[0].Object), "loaded object differs: %v", s.Objects[0].Object)
+}
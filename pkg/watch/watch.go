@@ -0,0 +1,438 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// Watcher keeps a graph of the cluster up to date with informers and re-evaluates the
+// rules for the objects affected by each change.
+type Watcher struct {
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	checker       *check.Checker
+	namespace     string
+	// resyncInterval is the interval at which all nodes are re-evaluated, as rules listing
+	// objects of other kinds depend on more than the neighbours of a node.
+	resyncInterval time.Duration
+	observer       Observer
+	handlers       []ResultsHandler
+
+	// updateMu serializes changes to the graph together with the evaluations of the changes, so that
+	// the graph is not modified while being evaluated and results are stored in the order of the changes.
+	updateMu sync.Mutex
+	// mu guards the fields below, the graph is only modified while holding both updateMu and mu.
+	mu            sync.RWMutex
+	graph         *graph.Graph
+	serverVersion string
+	nodeResults   map[string]map[string]*check.RuleResult
+	helmResults   map[string]*check.RuleResult
+	// built is true once the initial graph has been built from the informer caches.
+	built  bool
+	synced bool
+
+	// admissionMu guards the admission graph, which receives the same changes as the graph. Objects under
+	// review are added to it temporarily, so that neither the graph has to be copied nor reads of it are blocked.
+	admissionMu    sync.Mutex
+	admissionGraph *graph.Graph
+}
+
+const (
+	// ScopeFull is the scope of evaluations of all objects.
+	ScopeFull = "full"
+	// ScopeObject is the scope of evaluations of the objects affected by a change.
+	ScopeObject = "object"
+)
+
+// Observer is notified of every evaluation.
+type Observer interface {
+	ObserveEvaluation(scope string, duration time.Duration, err error)
+}
+
+// ResultsHandler is called with the results after all objects have been evaluated.
+type ResultsHandler func(ctx context.Context, results map[string]*check.RuleResult) error
+
+// Option configures the watcher.
+type Option func(w *Watcher)
+
+// WithNamespace scopes the watcher to a namespace.
+func WithNamespace(namespace string) Option {
+	return func(w *Watcher) {
+		w.namespace = namespace
+	}
+}
+
+// WithResyncInterval sets the interval at which all nodes are re-evaluated.
+func WithResyncInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.resyncInterval = interval
+	}
+}
+
+// WithObserver sets an observer which is notified of every evaluation.
+func WithObserver(observer Observer) Option {
+	return func(w *Watcher) {
+		w.observer = observer
+	}
+}
+
+// WithResultsHandler adds a handler which is called after all objects have been evaluated.
+func WithResultsHandler(handler ResultsHandler) Option {
+	return func(w *Watcher) {
+		w.handlers = append(w.handlers, handler)
+	}
+}
+
+func NewWatcher(client kubernetes.Interface, dynamicClient dynamic.Interface, checker *check.Checker, opts ...Option) *Watcher {
+	w := &Watcher{
+		client:         client,
+		dynamicClient:  dynamicClient,
+		checker:        checker,
+		resyncInterval: 5 * time.Minute,
+		graph:          graph.NewGraph(),
+		admissionGraph: graph.NewGraph(),
+		nodeResults:    map[string]map[string]*check.RuleResult{},
+		helmResults:    map[string]*check.RuleResult{},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run starts the informers and keeps the graph and results up to date until the context is cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx).WithName("watch")
+	logger.Info("discovering API resources")
+	serverVersion, err := w.client.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("could not get server version: %w", err)
+	}
+	gvrs, err := graph.DiscoverResources(ctx, w.client, w.namespace, "watch")
+	if err != nil {
+		return err
+	}
+
+	logger.Info("starting informers")
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(w.dynamicClient, 0, w.namespace, nil)
+	// Handlers are added before starting so that no changes are lost. Changes received before the initial
+	// graph has been built are dropped, as the informer caches are updated before the handlers are called
+	// and the graph is built from the caches.
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.handle(logger, obj, false)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			w.handle(logger, obj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			w.handle(logger, obj, true)
+		},
+	}
+	informers := []cache.SharedIndexInformer{}
+	for _, gvr := range gvrs {
+		informer := factory.ForResource(gvr).Informer()
+		informer.AddEventHandler(handler)
+		informers = append(informers, informer)
+	}
+	factory.Start(ctx.Done())
+	syncs := []cache.InformerSynced{}
+	for _, informer := range informers {
+		syncs = append(syncs, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), syncs...) {
+		return fmt.Errorf("could not sync informers: %w", ctx.Err())
+	}
+
+	// Build the initial graph from the informer caches. The caches are listed while holding updateMu, so that
+	// every change is either part of the list or applied by its handler once the graph has been built. Objects
+	// of the initial list handled after that are ignored as they are unchanged.
+	w.updateMu.Lock()
+	objects := []unstructured.Unstructured{}
+	for _, informer := range informers {
+		for _, obj := range informer.GetStore().List() {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+			objects = append(objects, *u)
+		}
+	}
+	w.mu.Lock()
+	w.serverVersion = serverVersion.GitVersion
+	err = w.graph.PopulateFromObjects(ctx, serverVersion.GitVersion, objects)
+	w.built = err == nil
+	w.mu.Unlock()
+	if err != nil {
+		w.updateMu.Unlock()
+		return err
+	}
+	w.admissionMu.Lock()
+	w.admissionGraph = w.graph.Copy()
+	w.admissionMu.Unlock()
+	results, err := w.resync()
+	w.updateMu.Unlock()
+	if err != nil {
+		return err
+	}
+	logger.Info("initial evaluation completed", "objects", len(objects), "violations", countViolations(results))
+	w.handleResults(ctx, logger, results)
+
+	ticker := time.NewTicker(w.resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.updateMu.Lock()
+			results, err := w.resync()
+			w.updateMu.Unlock()
+			if err != nil {
+				logger.Error(err, "could not evaluate rules")
+				continue
+			}
+			logger.Info("evaluated all objects", "violations", countViolations(results))
+			w.handleResults(ctx, logger, results)
+		}
+	}
+}
+
+// resync evaluates all objects and replaces the results, the caller has to hold updateMu.
+func (w *Watcher) resync() (map[string]*check.RuleResult, error) {
+	var nodeResults map[string]map[string]*check.RuleResult
+	var helmResults map[string]*check.RuleResult
+	err := w.observe(ScopeFull, func() error {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		var err error
+		nodeResults, helmResults, err = w.evaluateAll()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nodeResults = nodeResults
+	w.helmResults = helmResults
+	w.synced = true
+	return w.results(), nil
+}
+
+// Synced returns true once the initial graph has been built and evaluated.
+func (w *Watcher) Synced() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.synced
+}
+
+// ServerVersion returns the version of the cluster being watched.
+func (w *Watcher) ServerVersion() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.serverVersion
+}
+
+// GraphSize returns the number of nodes and edges in the graph.
+func (w *Watcher) GraphSize() (int, int) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.graph.Size()
+}
+
+// EvaluateObject evaluates the rules for the object as part of the graph, without keeping the object in the graph.
+// The object is added to the admission graph, so evaluations of objects are serialized but do not block the graph.
+func (w *Watcher) EvaluateObject(u unstructured.Unstructured) (map[string]*check.RuleResult, error) {
+	w.admissionMu.Lock()
+	defer w.admissionMu.Unlock()
+	var results map[string]*check.RuleResult
+	err := w.admissionGraph.WithObject(u, func(node *graph.Node) error {
+		var err error
+		results, err = w.checker.EvaluateNode(w.admissionGraph, node)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ObjectUID returns the UID of the object with the reference in the graph.
+func (w *Watcher) ObjectUID(reference graph.ObjectReference) (types.UID, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.graph.ObjectUID(reference)
+}
+
+// Results returns the current results of all rules.
+func (w *Watcher) Results() map[string]*check.RuleResult {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.results()
+}
+
+func (w *Watcher) results() map[string]*check.RuleResult {
+	results := []map[string]*check.RuleResult{}
+	for _, nodeResults := range w.nodeResults {
+		results = append(results, nodeResults)
+	}
+	results = append(results, w.helmResults)
+	return check.MergeResults(results...)
+}
+
+func (w *Watcher) handleResults(ctx context.Context, logger logr.Logger, results map[string]*check.RuleResult) {
+	for _, handler := range w.handlers {
+		err := handler(ctx, results)
+		if err != nil {
+			logger.Error(err, "could not handle results")
+		}
+	}
+}
+
+func countViolations(results map[string]*check.RuleResult) int {
+	count := 0
+	for _, result := range results {
+		count += len(result.Violations)
+	}
+	return count
+}
+
+// handle updates the graph with the changed object and re-evaluates the affected nodes, the change
+// is dropped if the initial graph has not been built yet as it is part of the informer caches.
+func (w *Watcher) handle(logger logr.Logger, obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	w.updateMu.Lock()
+	defer w.updateMu.Unlock()
+	// built is only modified while holding updateMu
+	if !w.built {
+		return
+	}
+	err := w.observe(ScopeObject, func() error {
+		return w.update(*u, deleted)
+	})
+	if err != nil {
+		logger.Error(err, "could not update graph", "object", objectName(*u))
+	}
+}
+
+func objectName(u unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", u.GroupVersionKind().String(), u.GetNamespace(), u.GetName())
+}
+
+// update applies the change to the graph and replaces the results of the affected nodes, the caller has
+// to hold updateMu. The rules are evaluated while only holding the read lock, so that reads are not blocked.
+func (w *Watcher) update(u unstructured.Unstructured, deleted bool) error {
+	w.mu.Lock()
+	affected, err := updateGraph(w.graph, u, deleted)
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	w.admissionMu.Lock()
+	_, err = updateGraph(w.admissionGraph, u, deleted)
+	w.admissionMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	isHelmRelease := graph.IsHelmReleaseSecret(u)
+	w.mu.RLock()
+	var nodeResults map[string]map[string]*check.RuleResult
+	var helmResults map[string]*check.RuleResult
+	if isHelmRelease {
+		helmResults, err = w.checker.EvaluateHelmReleases(w.graph)
+	} else {
+		nodeResults, err = w.evaluate(affected)
+	}
+	w.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if isHelmRelease {
+		w.helmResults = helmResults
+		return nil
+	}
+	if deleted {
+		delete(w.nodeResults, graph.ReferenceForObject(u).ID())
+	}
+	for id, results := range nodeResults {
+		w.nodeResults[id] = results
+	}
+	return nil
+}
+
+// updateGraph adds, replaces or removes the object in the graph and returns the affected nodes.
+func updateGraph(g *graph.Graph, u unstructured.Unstructured, deleted bool) ([]*graph.Node, error) {
+	if deleted {
+		return g.DeleteObject(u)
+	}
+	return g.UpsertObject(u)
+}
+
+// observe runs the evaluation and notifies the observer.
+func (w *Watcher) observe(scope string, f func() error) error {
+	start := time.Now()
+	err := f()
+	if w.observer != nil {
+		w.observer.ObserveEvaluation(scope, time.Since(start), err)
+	}
+	return err
+}
+
+// evaluate returns the results of the nodes keyed by reference ID.
+func (w *Watcher) evaluate(nodes []*graph.Node) (map[string]map[string]*check.RuleResult, error) {
+	nodeResults := map[string]map[string]*check.RuleResult{}
+	for _, node := range nodes {
+		results, err := w.checker.EvaluateNode(w.graph, node)
+		if err != nil {
+			return nil, err
+		}
+		nodeResults[node.Reference.ID()] = results
+	}
+	return nodeResults, nil
+}
+
+// evaluateAll returns the results of all nodes keyed by reference ID and the results of the Helm releases.
+func (w *Watcher) evaluateAll() (map[string]map[string]*check.RuleResult, map[string]*check.RuleResult, error) {
+	nodeResults := map[string]map[string]*check.RuleResult{}
+	err := w.graph.Iterate(func(node *graph.Node) error {
+		results, err := w.checker.EvaluateNode(w.graph, node)
+		if err != nil {
+			return err
+		}
+		nodeResults[node.Reference.ID()] = results
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	helmResults, err := w.checker.EvaluateHelmReleases(w.graph)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nodeResults, helmResults, nil
+}
@@ -0,0 +1,236 @@
+package watch
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+var (
+	podsGVR       = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+// testDiscovery returns the resources of the fake clientset as the preferred resources.
+type testDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d testDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+type testClientset struct {
+	*fake.Clientset
+}
+
+func (c testClientset) Discovery() discovery.DiscoveryInterface {
+	return testDiscovery{FakeDiscovery: c.Clientset.Discovery().(*fakediscovery.FakeDiscovery)}
+}
+
+// testObserver counts the evaluations of each scope.
+type testObserver struct {
+	mu     sync.Mutex
+	scopes map[string]int
+}
+
+func (o *testObserver) ObserveEvaluation(scope string, _ time.Duration, _ error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.scopes[scope]++
+}
+
+func (o *testObserver) count(scope string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.scopes[scope]
+}
+
+func newPod(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("Pod")
+	u.SetNamespace("foo")
+	u.SetName(name)
+	u.SetUID(types.UID("pod-" + name))
+	u.SetResourceVersion("1")
+	return u
+}
+
+func newTestWatcher(t *testing.T, objects []runtime.Object, opts ...Option) (*Watcher, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	client := testClientset{Clientset: fake.NewSimpleClientset()}
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"list", "watch"}, StorageVersionHash: "pods"},
+				// Resources which cannot be watched are skipped
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"list"}, StorageVersionHash: "configmaps"},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		podsGVR:       "PodList",
+		configMapsGVR: "ConfigMapList",
+	}, objects...)
+	checker, err := check.NewChecker(fstest.MapFS{"deprecated-versions.yaml": &fstest.MapFile{Data: []byte("[]")}})
+	require.NoError(t, err)
+	return NewWatcher(client, dynamicClient, checker, opts...), dynamicClient
+}
+
+func run(t *testing.T, w *Watcher) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-errCh)
+	})
+	require.Eventually(t, w.Synced, 5*time.Second, 10*time.Millisecond)
+}
+
+// violating returns the names of the objects violating the rule.
+func violating(w *Watcher, ruleID string) []string {
+	names := []string{}
+	result, ok := w.Results()[ruleID]
+	if !ok {
+		return names
+	}
+	for _, violation := range result.Violations {
+		names = append(names, violation.Reference.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestWatcher(t *testing.T) {
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetNamespace("foo")
+	configMap.SetName("config")
+	configMap.SetUID("config")
+	observer := &testObserver{scopes: map[string]int{}}
+	handled := make(chan map[string]*check.RuleResult, 1)
+	w, dynamicClient := newTestWatcher(t, []runtime.Object{newPod("web"), configMap},
+		WithObserver(observer),
+		WithResultsHandler(func(_ context.Context, results map[string]*check.RuleResult) error {
+			handled <- results
+			return nil
+		}),
+	)
+	run(t, w)
+
+	require.Equal(t, []string{"web"}, violating(w, "WithoutController"))
+	require.Len(t, (<-handled)["WithoutController"].Violations, 1)
+	require.Equal(t, 1, observer.count(ScopeFull))
+	_, ok := w.ObjectUID(graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web"})
+	require.True(t, ok)
+	_, ok = w.ObjectUID(graph.ReferenceForObject(*configMap))
+	require.False(t, ok)
+
+	// Added objects are evaluated
+	ctx := context.Background()
+	_, err := dynamicClient.Resource(podsGVR).Namespace("foo").Create(ctx, newPod("debug"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(violating(w, "WithoutController")) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"debug", "web"}, violating(w, "WithoutController"))
+
+	// The results of deleted objects are removed
+	require.NoError(t, dynamicClient.Resource(podsGVR).Namespace("foo").Delete(ctx, "web", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool {
+		return len(violating(w, "WithoutController")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"debug"}, violating(w, "WithoutController"))
+	_, ok = w.ObjectUID(graph.ObjectReference{ApiVersion: "v1", Kind: "Pod", Namespace: "foo", Name: "web"})
+	require.False(t, ok)
+	require.GreaterOrEqual(t, observer.count(ScopeObject), 2)
+}
+
+func TestWatcherResync(t *testing.T) {
+	handled := make(chan map[string]*check.RuleResult, 10)
+	w, _ := newTestWatcher(t, []runtime.Object{newPod("web")},
+		WithResyncInterval(10*time.Millisecond),
+		WithResultsHandler(func(_ context.Context, results map[string]*check.RuleResult) error {
+			select {
+			case handled <- results:
+			default:
+			}
+			return nil
+		}),
+	)
+	run(t, w)
+
+	// The results are handled after the initial evaluation and after every resync
+	for i := 0; i < 3; i++ {
+		select {
+		case results := <-handled:
+			require.Len(t, results["WithoutController"].Violations, 1)
+		case <-time.After(5 * time.Second):
+			t.Fatal("results were not handled")
+		}
+	}
+}
+
+func TestWatcherEvaluateObject(t *testing.T) {
+	w, dynamicClient := newTestWatcher(t, []runtime.Object{newPod("web")})
+	run(t, w)
+	nodes, edges := w.GraphSize()
+
+	results, err := w.EvaluateObject(*newPod("debug"))
+	require.NoError(t, err)
+	require.Len(t, results["WithoutController"].Violations, 1)
+	require.Equal(t, "debug", results["WithoutController"].Violations[0].Reference.Name)
+
+	// The object is not kept in the graph
+	afterNodes, afterEdges := w.GraphSize()
+	require.Equal(t, nodes, afterNodes)
+	require.Equal(t, edges, afterEdges)
+	require.Equal(t, []string{"web"}, violating(w, "WithoutController"))
+
+	// Objects are evaluated concurrently with changes, which are applied to the admission graph
+	ctx := context.Background()
+	errs := make(chan error, 5)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := w.EvaluateObject(*newPod("debug"))
+			errs <- err
+		}()
+	}
+	_, err = dynamicClient.Resource(podsGVR).Namespace("foo").Create(ctx, newPod("api"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	for i := 0; i < cap(errs); i++ {
+		require.NoError(t, <-errs)
+	}
+	require.Eventually(t, func() bool {
+		w.admissionMu.Lock()
+		defer w.admissionMu.Unlock()
+		return w.admissionGraph.NodeByReferenceID("v1/Pod/foo/api") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	w.admissionMu.Lock()
+	require.Nil(t, w.admissionGraph.NodeByReferenceID("v1/Pod/foo/debug"))
+	w.admissionMu.Unlock()
+}
@@ -0,0 +1,155 @@
+// Package webhook validates objects in admission requests against the rules.
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+)
+
+// Evaluator evaluates the rules for an object in the context of a graph.
+type Evaluator interface {
+	Synced() bool
+	EvaluateObject(u unstructured.Unstructured) (map[string]*check.RuleResult, error)
+}
+
+// skippedRules are the rules which are not evaluated in admission requests, as they check fields
+// which are set by the api server after admission.
+var skippedRules = map[string]bool{
+	"MissingManagedFields": true,
+}
+
+// Webhook is a validating admission webhook. Requests with violations of rules with a severity equal
+// to or above the deny severity are denied, other violations are returned as warnings. All requests
+// are allowed if the deny severity is zero.
+type Webhook struct {
+	evaluator    Evaluator
+	denySeverity uint
+	logger       logr.Logger
+}
+
+func NewWebhook(evaluator Evaluator, denySeverity uint, logger logr.Logger) *Webhook {
+	return &Webhook{
+		evaluator:    evaluator,
+		denySeverity: denySeverity,
+		logger:       logger,
+	}
+}
+
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	review := admissionv1.AdmissionReview{}
+	err := json.NewDecoder(req.Body).Decode(&review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not decode admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review does not contain a request", http.StatusBadRequest)
+		return
+	}
+	// Objects are evaluated without context until the graph is synced, so the api server should apply the failure policy
+	if !wh.evaluator.Synced() {
+		http.Error(w, "graph is not synced", http.StatusServiceUnavailable)
+		return
+	}
+
+	response, err := wh.review(review.Request)
+	if err != nil {
+		wh.logger.Error(err, "could not review admission request", "uid", review.Request.UID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.UID = review.Request.UID
+	review.Request = nil
+	review.Response = response
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(review)
+	if err != nil {
+		wh.logger.Error(err, "could not write admission review")
+	}
+}
+
+func (wh *Webhook) review(req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Operation == admissionv1.Delete || req.SubResource != "" || len(req.Object.Raw) == 0 {
+		return allowed, nil
+	}
+	u := unstructured.Unstructured{}
+	err := json.Unmarshal(req.Object.Raw, &u.Object)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode object: %w", err)
+	}
+	if u.GetAPIVersion() == "" || u.GetKind() == "" {
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind})
+	}
+	if u.GetNamespace() == "" {
+		u.SetNamespace(req.Namespace)
+	}
+	if u.GetName() == "" {
+		u.SetName(req.Name)
+	}
+	// Managed fields contain the api versions of earlier requests, only the api version of the request is checked
+	u.SetManagedFields(nil)
+	// Helm release secrets are not nodes in the graph
+	if graph.IsHelmReleaseSecret(u) {
+		return allowed, nil
+	}
+
+	ruleResults, err := wh.evaluator.EvaluateObject(u)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for id := range ruleResults {
+		if skippedRules[id] {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	denied := []string{}
+	warnings := []string{}
+	for _, id := range ids {
+		ruleResult := ruleResults[id]
+		for _, violation := range ruleResult.Violations {
+			message := fmt.Sprintf("%s: %s", ruleResult.Rule.ID, ruleResult.Rule.Description)
+			if violation.Message != "" {
+				message = fmt.Sprintf("%s %s", message, violation.Message)
+			}
+			if wh.denySeverity > 0 && ruleResult.Rule.Severity >= wh.denySeverity {
+				denied = append(denied, message)
+				continue
+			}
+			warnings = append(warnings, message)
+		}
+	}
+	if len(denied) == 0 {
+		allowed.Warnings = warnings
+		return allowed, nil
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: fmt.Sprintf("denied by kube-checker: %s", strings.Join(denied, ", ")),
+		},
+		Warnings: warnings,
+	}, nil
+}
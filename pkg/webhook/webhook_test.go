@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/xenitab/kube-checker/pkg/check"
+	"github.com/xenitab/kube-checker/pkg/graph"
+	"github.com/xenitab/kube-checker/pkg/watch"
+)
+
+type testEvaluator struct {
+	synced bool
+}
+
+func (e testEvaluator) Synced() bool {
+	return e.synced
+}
+
+func (e testEvaluator) EvaluateObject(u unstructured.Unstructured) (map[string]*check.RuleResult, error) {
+	reference := graph.ReferenceForObject(u)
+	ruleResults := map[string]*check.RuleResult{
+		"WithoutController": {
+			Rule:       check.Rule{ID: "WithoutController", Severity: 8, Description: "Pods should not be created without a controller."},
+			Violations: []check.Violation{{Reference: reference, Object: reference}},
+		},
+	}
+	if u.GetAPIVersion() == "policy/v1beta1" {
+		ruleResults["APIVersionRemoved/policy/v1beta1/PodDisruptionBudget"] = &check.RuleResult{
+			Rule:       check.Rule{ID: "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget", Severity: 10, Description: "Api version is removed."},
+			Violations: []check.Violation{{Reference: reference, Object: reference}},
+		}
+	}
+	return ruleResults, nil
+}
+
+// review sends an admission review creating the object to the webhook.
+func review(t *testing.T, wh *Webhook, object map[string]interface{}) (int, *admissionv1.AdmissionResponse) {
+	t.Helper()
+	raw, err := json.Marshal(object)
+	require.NoError(t, err)
+	b, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "1234",
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(b)))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	resp := admissionv1.AdmissionReview{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "1234", string(resp.Response.UID))
+	return rec.Code, resp.Response
+}
+
+func TestWebhook(t *testing.T) {
+	pdb := func(apiVersion string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       "PodDisruptionBudget",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "foo"},
+		}
+	}
+
+	code, _ := review(t, NewWebhook(testEvaluator{}, 10, logr.Discard()), pdb("policy/v1"))
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	_, resp := review(t, NewWebhook(testEvaluator{synced: true}, 10, logr.Discard()), pdb("policy/v1"))
+	require.True(t, resp.Allowed)
+	require.Equal(t, []string{"WithoutController: Pods should not be created without a controller."}, resp.Warnings)
+
+	_, resp = review(t, NewWebhook(testEvaluator{synced: true}, 10, logr.Discard()), pdb("policy/v1beta1"))
+	require.False(t, resp.Allowed)
+	require.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+	require.Contains(t, resp.Result.Message, "APIVersionRemoved/policy/v1beta1/PodDisruptionBudget")
+	require.Len(t, resp.Warnings, 1)
+}
+
+// testDiscovery returns the resources of the fake clientset as the preferred resources.
+type testDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d testDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+type testClientset struct {
+	*fake.Clientset
+}
+
+func (c testClientset) Discovery() discovery.DiscoveryInterface {
+	return testDiscovery{FakeDiscovery: c.Clientset.Discovery().(*fakediscovery.FakeDiscovery)}
+}
+
+func TestWebhookWithWatcher(t *testing.T) {
+	replicaSet := &unstructured.Unstructured{}
+	replicaSet.SetAPIVersion("apps/v1")
+	replicaSet.SetKind("ReplicaSet")
+	replicaSet.SetNamespace("foo")
+	replicaSet.SetName("web")
+	replicaSet.SetUID("00000000-0000-0000-0000-000000000001")
+
+	client := testClientset{Clientset: fake.NewSimpleClientset()}
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"list", "watch"}, StorageVersionHash: "pods"}},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: metav1.Verbs{"list", "watch"}, StorageVersionHash: "replicasets"}},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "pods"}:                       "PodList",
+		{Group: "apps", Version: "v1", Resource: "replicasets"}: "ReplicaSetList",
+	}, replicaSet)
+	checker, err := check.NewChecker(fstest.MapFS{"deprecated-versions.yaml": &fstest.MapFile{Data: []byte("[]")}})
+	require.NoError(t, err)
+	watcher := watch.NewWatcher(client, dynamicClient, checker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- watcher.Run(ctx)
+	}()
+	require.Eventually(t, watcher.Synced, 5*time.Second, 10*time.Millisecond)
+	nodes, edges := watcher.GraphSize()
+
+	pod := func(ownerReferences []interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name":            "web-abc",
+				"namespace":       "foo",
+				"ownerReferences": ownerReferences,
+				"managedFields":   []interface{}{map[string]interface{}{"manager": "kubectl", "apiVersion": "v1"}},
+			},
+		}
+	}
+	// All violations are returned as warnings
+	wh := NewWebhook(watcher, 0, logr.Discard())
+
+	// Pods owned by a controller in the graph are allowed, managed fields are not checked
+	_, resp := review(t, wh, pod([]interface{}{map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "ReplicaSet",
+		"name":       replicaSet.GetName(),
+		"uid":        string(replicaSet.GetUID()),
+		"controller": true,
+	}}))
+	require.True(t, resp.Allowed)
+	require.NotEmpty(t, resp.Warnings)
+	for _, warning := range resp.Warnings {
+		require.NotContains(t, warning, "WithoutController")
+		require.NotContains(t, warning, "MissingManagedFields")
+	}
+
+	// Pods without a controller violate the rule
+	_, resp = review(t, wh, pod(nil))
+	require.Contains(t, resp.Warnings, "WithoutController: Pods should not be created without a controller.")
+
+	// The objects under review are not kept in the graph
+	afterNodes, afterEdges := watcher.GraphSize()
+	require.Equal(t, nodes, afterNodes)
+	require.Equal(t, edges, afterEdges)
+
+	cancel()
+	require.NoError(t, <-errCh)
+}